@@ -17,7 +17,13 @@ var (
 func main() {
 	opts := server.Options{}
 	var showVersion bool
+	var admins string
+	var bans string
+	var blocks string
+	var config string
 
+	flag.StringVar(&config, "C", "", "Configuration file to load.")
+	flag.StringVar(&config, "--config", "", "Configuration file to load.")
 	flag.StringVar(&opts.Name, "N", "", "Name of the server.")
 	flag.StringVar(&opts.Name, "--name", "", "Name of the server.")
 	flag.StringVar(&opts.Hostname, "H", server.DefaultHostname, "Hostname of the server")
@@ -34,6 +40,68 @@ func main() {
 	flag.IntVar(&opts.MaxIdle, "--idle", server.DefaultMaxIdle, "Maximum client idle allowed.")
 	flag.IntVar(&opts.MaxProcs, "X", server.DefaultMaxProcs, "Maximum processor cores to use.")
 	flag.IntVar(&opts.MaxProcs, "--procs", server.DefaultMaxProcs, "Maximum processor cores to use.")
+	flag.IntVar(&opts.MaxHist, "y", server.DefaultMaxHist, "Maximum history messages replayed on join.")
+	flag.IntVar(&opts.MaxHist, "--history", server.DefaultMaxHist, "Maximum history messages replayed on join.")
+	flag.StringVar(&opts.HistDir, "Y", server.DefaultHistDir, "Directory to store room history.")
+	flag.StringVar(&opts.HistDir, "--history_dir", server.DefaultHistDir, "Directory to store room history.")
+	flag.StringVar(&admins, "a", "", "Comma separated nicknames allowed to administer rooms.")
+	flag.StringVar(&admins, "--admins", "", "Comma separated nicknames allowed to administer rooms.")
+	flag.StringVar(&opts.AuthSecret, "k", "", "Shared secret to verify chat authentication tokens.")
+	flag.StringVar(&opts.AuthSecret, "--auth_secret", "", "Shared secret to verify chat authentication tokens.")
+	flag.StringVar(&opts.NickFile, "R", server.DefaultNickFile, "File to store registered nicknames.")
+	flag.StringVar(&opts.NickFile, "--nickname_file", server.DefaultNickFile, "File to store registered nicknames.")
+	flag.BoolVar(&opts.UniqueNick, "u", false, "Require nicknames to be unique across the server.")
+	flag.BoolVar(&opts.UniqueNick, "--unique_nicknames", false, "Require nicknames to be unique across the server.")
+	flag.StringVar(&opts.TLSCert, "c", "", "Certificate file to serve TLS with.")
+	flag.StringVar(&opts.TLSCert, "--tls_cert", "", "Certificate file to serve TLS with.")
+	flag.StringVar(&opts.TLSKey, "K", "", "Private key file to serve TLS with.")
+	flag.StringVar(&opts.TLSKey, "--tls_key", "", "Private key file to serve TLS with.")
+	flag.StringVar(&opts.TLSCA, "A", "", "CA file to verify client certificates against.")
+	flag.StringVar(&opts.TLSCA, "--tls_ca", "", "CA file to verify client certificates against.")
+	flag.StringVar(&bans, "b", "", "Comma separated nicknames and IPs banned from the server.")
+	flag.StringVar(&bans, "--bans", "", "Comma separated nicknames and IPs banned from the server.")
+	flag.StringVar(&opts.MOTD, "m", "", "Message of the day sent to chatters on connect.")
+	flag.StringVar(&opts.MOTD, "--motd", "", "Message of the day sent to chatters on connect.")
+	flag.IntVar(&opts.MsgRate, "s", server.DefaultMsgRate, "Messages per second allowed to each chatter.")
+	flag.IntVar(&opts.MsgRate, "--msg_rate", server.DefaultMsgRate, "Messages per second allowed to each chatter.")
+	flag.IntVar(&opts.MsgBurst, "B", server.DefaultMsgBurst, "Messages allowed at once to each chatter.")
+	flag.IntVar(&opts.MsgBurst, "--msg_burst", server.DefaultMsgBurst, "Messages allowed at once to each chatter.")
+	flag.IntVar(&opts.JoinRate, "j", server.DefaultJoinRate, "Room joins per minute allowed to each chatter.")
+	flag.IntVar(&opts.JoinRate, "--join_rate", server.DefaultJoinRate, "Room joins per minute allowed to each chatter.")
+	flag.IntVar(&opts.MaxThrottle, "f", server.DefaultMaxThrottle, "Throttled requests per minute before a disconnect.")
+	flag.IntVar(&opts.MaxThrottle, "--flood_limit", server.DefaultMaxThrottle, "Throttled requests per minute before a disconnect.")
+	flag.IntVar(&opts.MaxFrame, "F", server.DefaultMaxFrame, "Maximum bytes in a request frame.")
+	flag.IntVar(&opts.MaxFrame, "--max_frame", server.DefaultMaxFrame, "Maximum bytes in a request frame.")
+	flag.IntVar(&opts.MaxMsgLen, "M", server.DefaultMaxMsgLen, "Maximum characters in a message.")
+	flag.IntVar(&opts.MaxMsgLen, "--max_message", server.DefaultMaxMsgLen, "Maximum characters in a message.")
+	flag.IntVar(&opts.MaxNickLen, "e", server.DefaultMaxNickLen, "Maximum characters in a nickname.")
+	flag.IntVar(&opts.MaxNickLen, "--max_nickname", server.DefaultMaxNickLen, "Maximum characters in a nickname.")
+	flag.IntVar(&opts.MaxRoomLen, "o", server.DefaultMaxRoomLen, "Maximum characters in a room name.")
+	flag.IntVar(&opts.MaxRoomLen, "--max_room_name", server.DefaultMaxRoomLen, "Maximum characters in a room name.")
+	flag.StringVar(&opts.WordFile, "W", "", "File of words masked in messages.")
+	flag.StringVar(&opts.WordFile, "--word_file", "", "File of words masked in messages.")
+	flag.BoolVar(&opts.StripLinks, "l", false, "Remove links from messages.")
+	flag.BoolVar(&opts.StripLinks, "--strip_links", false, "Remove links from messages.")
+	flag.StringVar(&blocks, "P", "", "Comma separated regular expressions of messages refused.")
+	flag.StringVar(&blocks, "--block", "", "Comma separated regular expressions of messages refused.")
+	flag.IntVar(&opts.TypingTTL, "T", server.DefaultTypingTTL, "Seconds a typing notice lasts without a stop.")
+	flag.IntVar(&opts.TypingTTL, "--typing_timeout", server.DefaultTypingTTL, "Seconds a typing notice lasts without a stop.")
+	flag.BoolVar(&opts.TypingIdle, "I", false, "Typing notices reset the idle timeout.")
+	flag.BoolVar(&opts.TypingIdle, "--typing_idle", false, "Typing notices reset the idle timeout.")
+	flag.IntVar(&opts.AwayIdle, "z", server.DefaultAwayIdle, "Seconds idle before a chatter is shown as away.")
+	flag.IntVar(&opts.AwayIdle, "--away", server.DefaultAwayIdle, "Seconds idle before a chatter is shown as away.")
+	flag.IntVar(&opts.PingPeriod, "G", server.DefaultPingPeriod, "Seconds between pings to each chatter.")
+	flag.IntVar(&opts.PingPeriod, "--ping", server.DefaultPingPeriod, "Seconds between pings to each chatter.")
+	flag.IntVar(&opts.PongWait, "Q", server.DefaultPongWait, "Seconds a chatter has to answer a ping.")
+	flag.IntVar(&opts.PongWait, "--pong_timeout", server.DefaultPongWait, "Seconds a chatter has to answer a ping.")
+	flag.IntVar(&opts.WriteWait, "D", server.DefaultWriteWait, "Seconds a send to a chatter may block.")
+	flag.IntVar(&opts.WriteWait, "--write_timeout", server.DefaultWriteWait, "Seconds a send to a chatter may block.")
+	flag.IntVar(&opts.Grace, "g", server.DefaultGrace, "Seconds allowed on shutdown for queues to drain.")
+	flag.IntVar(&opts.Grace, "--grace", server.DefaultGrace, "Seconds allowed on shutdown for queues to drain.")
+	flag.IntVar(&opts.Reconnect, "w", server.DefaultReconnect, "Seconds chatters wait to reconnect after a shutdown.")
+	flag.IntVar(&opts.Reconnect, "--reconnect", server.DefaultReconnect, "Seconds chatters wait to reconnect after a shutdown.")
+	flag.StringVar(&opts.AdminToken, "t", "", "Token to authorize admin API requests.")
+	flag.StringVar(&opts.AdminToken, "--admin_token", "", "Token to authorize admin API requests.")
 	flag.BoolVar(&opts.Debug, "d", false, "Enable debugging output.")
 	flag.BoolVar(&opts.Debug, "--debug", false, "Enable debugging output.")
 	flag.BoolVar(&showVersion, "V", false, "Show version.")
@@ -56,12 +124,44 @@ func main() {
 		}
 	}
 
+	// The configuration file and environment override the defaults, then flags override both.
+	// This is repeated when the server is asked to reload.
+	path := config
+	set := make(map[string]string)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = f.Value.String() })
+	resolve := func() (*server.Options, error) {
+		opts = server.Options{}
+		flag.VisitAll(func(f *flag.Flag) { flag.Set(f.Name, f.DefValue) })
+		if err := opts.Load(path); err != nil {
+			return nil, err
+		}
+		for name, val := range set {
+			flag.Set(name, val)
+		}
+		if admins != "" {
+			opts.Admins = strings.Split(admins, ",")
+		}
+		if bans != "" {
+			opts.Bans = strings.Split(bans, ",")
+		}
+		if blocks != "" {
+			opts.Blocks = strings.Split(blocks, ",")
+		}
+		o := opts
+		return &o, o.Validate()
+	}
+	ops, err := resolve()
+	if err != nil {
+		log.Emergencyf("Configuration error: %s", err.Error())
+	}
+
 	// Set thread and proc usage.
-	if opts.MaxProcs > 0 {
-		runtime.GOMAXPROCS(opts.MaxProcs)
+	if ops.MaxProcs > 0 {
+		runtime.GOMAXPROCS(ops.MaxProcs)
 	}
 	log.Infof("NumCPU %d GOMAXPROCS: %d\n", runtime.NumCPU(), runtime.GOMAXPROCS(-1))
 
-	s := server.New(&opts)
+	s := server.New(ops)
+	s.SetConfigLoader(resolve)
 	s.Start()
 }
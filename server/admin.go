@@ -0,0 +1,207 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// adminError is an admin API failure with the http status it is returned with.
+type adminError struct {
+	status int    // The http status code.
+	msg    string // The reason returned to the client.
+}
+
+// Error is an implementation of the error interface.
+func (e *adminError) Error() string {
+	return e.msg
+}
+
+// adminErrorNew returns an admin API failure for an error from the chat manager.
+func adminErrorNew(err error) *adminError {
+	switch err {
+	case chatManagerErrRoomNotFound, chatManagerErrNicknameNotFound:
+		return &adminError{http.StatusNotFound, err.Error()}
+	case chatManagerErrRoomExists, chatManagerErrRoomNotEmpty, chatManagerErrNicknameAmbiguous:
+		return &adminError{http.StatusConflict, err.Error()}
+	}
+	return &adminError{http.StatusInternalServerError, err.Error()}
+}
+
+// adminRequest is the body of an admin API request that changes a room or chatter.
+type adminRequest struct {
+	Name     string `json:"name"`     // The new name of a room.
+	Content  string `json:"content"`  // The text of an announcement.
+	Nickname string `json:"nickname"` // The nickname of a chatter.
+}
+
+// adminHandler handles the admin API. Each request must carry the admin token in an
+// "Authorization: Bearer" header. The routes below /v1.0/admin/ are:
+//
+//	GET    rooms                         list the rooms.
+//	GET    rooms/{room}                  show a room and its members.
+//	DELETE rooms/{room}                  delete an empty room.
+//	POST   rooms/{room}/rename           rename an empty room to {"name":"..."}.
+//	POST   rooms/{room}/announce         post {"content":"..."} to the room.
+//	POST   rooms/{room}/kick             kick {"nickname":"..."} from the room.
+//	POST   chatters/{nickname}/disconnect disconnect a chatter or remove a bot from the server.
+//
+// Every request is recorded in the audit log.
+func (s *Server) adminHandler(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.stats.IncrReqStats(r.ContentLength)
+	s.stats.IncrRouteStats(httpRouteV1Admin, r.ContentLength)
+	s.mu.Unlock()
+	s.initResponseHeader(w)
+
+	action := r.Method + " " + r.URL.Path
+	var result interface{}
+	err := s.adminAuthorize(r)
+	if err == nil {
+		result, err = s.adminRoute(r)
+	}
+	if err != nil {
+		ae, ok := err.(*adminError)
+		if !ok {
+			ae = adminErrorNew(err)
+		}
+		s.log.LogAdmin(r.RemoteAddr, action, ae.msg)
+		w.WriteHeader(ae.status)
+		b, _ := json.Marshal(&struct {
+			Error string `json:"error"`
+		}{ae.msg})
+		w.Write(b)
+		return
+	}
+	s.log.LogAdmin(r.RemoteAddr, action, "ok")
+	b, _ := json.Marshal(result)
+	w.Write(b)
+}
+
+// adminAuthorize validates the admin token of a request. The API is not available if no admin
+// token is configured.
+func (s *Server) adminAuthorize(r *http.Request) error {
+	s.mu.RLock()
+	token := s.adminToken
+	s.mu.RUnlock()
+	if token == nil {
+		return &adminError{http.StatusNotFound, "admin API is not enabled"}
+	}
+	h := r.Header.Get("Authorization")
+	if !strings.HasPrefix(h, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(h, "Bearer ")), token) != 1 {
+		return &adminError{http.StatusUnauthorized, "admin token is missing or not valid"}
+	}
+	return nil
+}
+
+// adminRoute dispatches an authorized admin request and returns the result to send back.
+func (s *Server) adminRoute(r *http.Request) (interface{}, error) {
+	path, err := adminPath(r.URL)
+	if err != nil {
+		return nil, err
+	}
+	var q adminRequest
+	if r.Method == "POST" {
+		if err := json.NewDecoder(r.Body).Decode(&q); err != nil {
+			return nil, &adminError{http.StatusBadRequest, "request body must be a JSON object"}
+		}
+	}
+	switch {
+	case r.Method == "GET" && len(path) == 1 && path[0] == "rooms":
+		return s.cMngr.getRoomStats(), nil
+	case len(path) == 2 && path[0] == "rooms":
+		room, err := s.cMngr.find(path[1])
+		if err != nil {
+			return nil, err
+		}
+		switch r.Method {
+		case "GET":
+			return room.ChatRoomStatsNew(), nil
+		case "DELETE":
+			if err := s.cMngr.deleteRoom(path[1], nil); err != nil {
+				return nil, err
+			}
+			return adminResult(fmt.Sprintf(`Room "%s" deleted.`, path[1])), nil
+		}
+	case r.Method == "POST" && len(path) == 3 && path[0] == "rooms":
+		return s.adminRoomAction(path[1], path[2], &q)
+	case r.Method == "POST" && len(path) == 3 && path[0] == "chatters" && path[2] == "disconnect":
+		c, err := s.cMngr.findChatter(path[1])
+		if err != nil {
+			return nil, err
+		}
+		if c.isBot() {
+			if err := s.cMngr.removeBot(path[1]); err != nil {
+				return nil, err
+			}
+			return adminResult(fmt.Sprintf(`Bot "%s" removed.`, path[1])), nil
+		}
+		s.log.LogSession("disconnected", c.remoteAddr(), "Client disconnected by an administrator.")
+		c.disconnect(ChatRspTypeErrDisconnected, "You have been disconnected by the server administrator.")
+		return adminResult(fmt.Sprintf(`Chatter "%s" disconnected.`, path[1])), nil
+	}
+	return nil, &adminError{http.StatusNotFound, "unknown admin request"}
+}
+
+// adminRoomAction performs a POST admin request on a room.
+func (s *Server) adminRoomAction(name string, action string, q *adminRequest) (interface{}, error) {
+	room, err := s.cMngr.find(name)
+	if err != nil {
+		return nil, err
+	}
+	switch action {
+	case "rename":
+		if q.Name == "" {
+			return nil, &adminError{http.StatusBadRequest, "name is mandatory"}
+		}
+		if err := s.cMngr.renameRoom(name, q.Name, nil); err != nil {
+			return nil, err
+		}
+		return adminResult(fmt.Sprintf(`Room "%s" renamed to "%s".`, name, q.Name)), nil
+	case "announce":
+		if q.Content == "" {
+			return nil, &adminError{http.StatusBadRequest, "content is mandatory"}
+		}
+		room.announce(q.Content)
+		return adminResult(fmt.Sprintf(`Announcement sent to room "%s".`, name)), nil
+	case "kick":
+		if q.Nickname == "" {
+			return nil, &adminError{http.StatusBadRequest, "nickname is mandatory"}
+		}
+		if err := room.adminKick(q.Nickname); err != nil {
+			return nil, err
+		}
+		return adminResult(fmt.Sprintf(`Chatter "%s" kicked from room "%s".`, q.Nickname, name)), nil
+	}
+	return nil, &adminError{http.StatusNotFound, "unknown admin request"}
+}
+
+// adminResult returns the body of a successful admin change.
+func adminResult(msg string) interface{} {
+	return &struct {
+		Result string `json:"result"`
+	}{msg}
+}
+
+// adminPath returns the unescaped segments of an admin request path after the admin route, so
+// room names and nicknames may contain escaped slashes.
+func adminPath(u *url.URL) ([]string, error) {
+	p := strings.TrimPrefix(u.EscapedPath(), httpRouteV1Admin)
+	p = strings.Trim(p, "/")
+	if p == "" {
+		return nil, &adminError{http.StatusNotFound, "unknown admin request"}
+	}
+	segs := strings.Split(p, "/")
+	for i, seg := range segs {
+		v, err := url.PathUnescape(seg)
+		if err != nil {
+			return nil, &adminError{http.StatusBadRequest, "request path is not valid"}
+		}
+		segs[i] = v
+	}
+	return segs, nil
+}
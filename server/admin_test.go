@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tTestAdminServer returns a server with only what the admin API needs.
+func tTestAdminServer(token string) *Server {
+	l := ChatLoggerNew()
+	s := &Server{
+		info:  InfoNew(),
+		opts:  &Options{},
+		stats: StatsNew(),
+		cMngr: ChatManagerNew(0, 0, 0, "", l),
+		log:   l,
+	}
+	if token != "" {
+		s.adminToken = []byte(token)
+	}
+	return s
+}
+
+// tTestAdminDo sends a request to the admin handler and returns the status and body.
+func tTestAdminDo(s *Server, token string, method string, path string, body string) (int, string) {
+	rq := httptest.NewRequest(method, httpRouteV1Admin+path, strings.NewReader(body))
+	if token != "" {
+		rq.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	s.adminHandler(w, rq)
+	return w.Code, w.Body.String()
+}
+
+func TestAdminDisabled(t *testing.T) {
+	t.Parallel()
+	s := tTestAdminServer("")
+	if st, _ := tTestAdminDo(s, "Anything", "GET", "rooms", ""); st != http.StatusNotFound {
+		t.Errorf("Admin API should not be available without a token. Actual: %d", st)
+	}
+}
+
+func TestAdminRooms(t *testing.T) {
+	t.Parallel()
+	const token = "Sesame"
+	s := tTestAdminServer(token)
+	defer s.cMngr.shutdownAll()
+	s.cMngr.createRoom("Room 1/A")
+
+	tests := []struct {
+		desc   string
+		token  string
+		method string
+		path   string
+		body   string
+		status int
+		exp    string
+	}{
+		{"No token", "", "GET", "rooms", "", http.StatusUnauthorized, "admin token is missing"},
+		{"Wrong token", "Wrong", "GET", "rooms", "", http.StatusUnauthorized, "admin token is missing"},
+		{"List rooms", token, "GET", "rooms", "", http.StatusOK, `"name":"Room 1/A"`},
+		{"Show room", token, "GET", "rooms/Room%201%2FA", "", http.StatusOK, `"chatters":[]`},
+		{"Unknown room", token, "GET", "rooms/Nowhere", "", http.StatusNotFound, "chatroom not found"},
+		{"Rename no name", token, "POST", "rooms/Room%201%2FA/rename", "{}", http.StatusBadRequest, "name is mandatory"},
+		{"Bad body", token, "POST", "rooms/Room%201%2FA/rename", "name", http.StatusBadRequest, "JSON object"},
+		{"Rename", token, "POST", "rooms/Room%201%2FA/rename", `{"name":"Room2"}`, http.StatusOK, `renamed to \"Room2\"`},
+		{"Old name", token, "GET", "rooms/Room%201%2FA", "", http.StatusNotFound, "chatroom not found"},
+		{"Announce no content", token, "POST", "rooms/Room2/announce", "{}", http.StatusBadRequest, "content is mandatory"},
+		{"Kick non member", token, "POST", "rooms/Room2/kick", `{"nickname":"Ghost"}`, http.StatusNotFound, "nickname not found"},
+		{"Disconnect unknown", token, "POST", "chatters/Ghost/disconnect", "{}", http.StatusNotFound, "nickname not found"},
+		{"Unknown action", token, "POST", "rooms/Room2/paint", "{}", http.StatusNotFound, "unknown admin request"},
+		{"Unknown route", token, "GET", "", "", http.StatusNotFound, "unknown admin request"},
+		{"Delete", token, "DELETE", "rooms/Room2", "", http.StatusOK, `Room \"Room2\" deleted.`},
+		{"List empty", token, "GET", "rooms", "", http.StatusOK, "[]"},
+	}
+	for _, tc := range tests {
+		st, body := tTestAdminDo(s, tc.token, tc.method, tc.path, tc.body)
+		if st != tc.status || !strings.Contains(body, tc.exp) {
+			t.Errorf("%s error.\nExpected: %d %s\n\nActual: %d %s\n", tc.desc, tc.status, tc.exp, st, body)
+		}
+	}
+}
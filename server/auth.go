@@ -0,0 +1,89 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+var (
+	authErrTokenMissing   = errors.New("authentication token is missing")
+	authErrTokenMalformed = errors.New("authentication token is malformed")
+	authErrTokenAlgorithm = errors.New("authentication token algorithm is not HS256")
+	authErrTokenSignature = errors.New("authentication token signature is invalid")
+	authErrTokenExpired   = errors.New("authentication token has expired")
+	authErrTokenNotBefore = errors.New("authentication token is not valid yet")
+	authErrTokenSubject   = errors.New("authentication token has no subject")
+)
+
+// authClaims are the registered JWT claims used to authenticate a chatter.
+type authClaims struct {
+	Subject   string `json:"sub"`           // The identity of the chatter, used as the nickname.
+	ExpiresAt int64  `json:"exp,omitempty"` // Unix time after which the token is rejected.
+	NotBefore int64  `json:"nbf,omitempty"` // Unix time before which the token is rejected.
+}
+
+// authToken returns the token carried by a handshake request, either as a bearer token in the
+// Authorization header or, for browsers that cannot set headers, in the token query parameter.
+func authToken(r *http.Request) string {
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+	}
+	return r.URL.Query().Get("token")
+}
+
+// authVerify validates a JWT signed with HMAC SHA-256 using the shared secret and returns its
+// claims.
+func authVerify(tok string, secret []byte, now time.Time) (*authClaims, error) {
+	if tok == "" {
+		return nil, authErrTokenMissing
+	}
+	parts := strings.Split(tok, ".")
+	if len(parts) != 3 {
+		return nil, authErrTokenMalformed
+	}
+	hb, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, authErrTokenMalformed
+	}
+	var hdr struct {
+		Alg string `json:"alg"`
+	}
+	if err := json.Unmarshal(hb, &hdr); err != nil {
+		return nil, authErrTokenMalformed
+	}
+	if hdr.Alg != "HS256" {
+		return nil, authErrTokenAlgorithm
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, authErrTokenMalformed
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return nil, authErrTokenSignature
+	}
+	pb, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, authErrTokenMalformed
+	}
+	claims := &authClaims{}
+	if err := json.Unmarshal(pb, claims); err != nil {
+		return nil, authErrTokenMalformed
+	}
+	switch {
+	case claims.ExpiresAt > 0 && now.Unix() >= claims.ExpiresAt:
+		return nil, authErrTokenExpired
+	case claims.NotBefore > 0 && now.Unix() < claims.NotBefore:
+		return nil, authErrTokenNotBefore
+	case claims.Subject == "":
+		return nil, authErrTokenSubject
+	}
+	return claims, nil
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"testing"
+	"time"
+)
+
+const (
+	testAuthSecret = "Shhhhh"
+)
+
+// tTestAuthSign returns a JWT for the claims signed with the secret.
+func tTestAuthSign(alg string, claims string, secret string) string {
+	enc := base64.RawURLEncoding
+	tok := enc.EncodeToString([]byte(`{"alg":"`+alg+`","typ":"JWT"}`)) + "." + enc.EncodeToString([]byte(claims))
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(tok))
+	return tok + "." + enc.EncodeToString(mac.Sum(nil))
+}
+
+func TestAuthVerify(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1500000000, 0)
+	tests := []struct {
+		desc string
+		tok  string
+		err  error
+	}{
+		{"valid", tTestAuthSign("HS256", `{"sub":"ChatMonkey","exp":1500000060}`, testAuthSecret), nil},
+		{"no expiry", tTestAuthSign("HS256", `{"sub":"ChatMonkey"}`, testAuthSecret), nil},
+		{"missing", "", authErrTokenMissing},
+		{"malformed", "abc.def", authErrTokenMalformed},
+		{"algorithm", tTestAuthSign("none", `{"sub":"ChatMonkey"}`, testAuthSecret), authErrTokenAlgorithm},
+		{"signature", tTestAuthSign("HS256", `{"sub":"ChatMonkey"}`, "wrong"), authErrTokenSignature},
+		{"expired", tTestAuthSign("HS256", `{"sub":"ChatMonkey","exp":1499999999}`, testAuthSecret),
+			authErrTokenExpired},
+		{"not before", tTestAuthSign("HS256", `{"sub":"ChatMonkey","nbf":1500000001}`, testAuthSecret),
+			authErrTokenNotBefore},
+		{"subject", tTestAuthSign("HS256", `{"exp":1500000060}`, testAuthSecret), authErrTokenSubject},
+	}
+	for _, tc := range tests {
+		claims, err := authVerify(tc.tok, []byte(testAuthSecret), now)
+		if err != tc.err {
+			t.Errorf("Token %s error.\nExpected: %v\n\nActual: %v\n", tc.desc, tc.err, err)
+		}
+		if err == nil && claims.Subject != "ChatMonkey" {
+			t.Errorf("Token %s subject incorrect. Actual: %s", tc.desc, claims.Subject)
+		}
+	}
+}
+
+func TestAuthToken(t *testing.T) {
+	t.Parallel()
+	r, _ := http.NewRequest("GET", "http://localhost/v1.0/chat?token=abc", nil)
+	if tok := authToken(r); tok != "abc" {
+		t.Errorf("Token should be read from the query. Actual: %s", tok)
+	}
+	r.Header.Set("Authorization", "Bearer def")
+	if tok := authToken(r); tok != "def" {
+		t.Errorf("Token should be read from the header first. Actual: %s", tok)
+	}
+}
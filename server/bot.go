@@ -0,0 +1,99 @@
+package server
+
+import "time"
+
+const (
+	chatterBotMarker = " [bot]" // Follows the nickname of a bot in room member lists.
+)
+
+// Bot is a helper that runs inside the server as a virtual chatter without a websocket, ex: for
+// reminders, dice or answers to questions. Start is called when the bot is connected, and again
+// if the server is restarted, so it can join its rooms. Receive is then called for each response
+// the rooms send the bot, the same as those sent to any other chatter, including the messages the
+// bot posts itself. Both are called from one go routine per bot.
+type Bot interface {
+	Start(c *BotChatter)
+	Receive(c *BotChatter, rsp *ChatResponse)
+}
+
+// BotChatter is the chatter of a bot. Its requests are sent to rooms the same way as requests from
+// a remote client, but are not rate limited or filtered.
+type BotChatter struct {
+	c *Chatter // The chatter the bot is attached to.
+}
+
+// Nickname returns the nickname of the bot.
+func (b *BotChatter) Nickname() string {
+	return b.c.Nickname()
+}
+
+// Join joins a room, creating it if it does not exist.
+func (b *BotChatter) Join(room string) {
+	b.request(room, ChatReqTypeJoin, "", "")
+}
+
+// Leave leaves a room.
+func (b *BotChatter) Leave(room string) {
+	b.request(room, ChatReqTypeLeave, "", "")
+}
+
+// Post posts a message to a room the bot has joined.
+func (b *BotChatter) Post(room string, content string) {
+	b.request(room, ChatReqTypeMsg, content, "")
+}
+
+// PrivateMessage sends a message to a single chatter. With a room name the recipient is looked up
+// in the room, otherwise anywhere on the server.
+func (b *BotChatter) PrivateMessage(room string, target string, content string) {
+	b.request(room, ChatReqTypePrivateMsg, content, target)
+}
+
+// request sends a request from the bot to a room.
+func (b *BotChatter) request(room string, rtype int, content string, target string) {
+	b.c.mu.Lock()
+	b.c.lastReq = time.Now()
+	b.c.reqCount++
+	b.c.mu.Unlock()
+	r := &ChatRequest{Who: b.c, RoomName: room, ReqType: rtype, Content: content, Target: target}
+	if rtype == ChatReqTypePrivateMsg {
+		b.c.privateMessage(r)
+		return
+	}
+	b.c.sendRequestToRoom(r)
+}
+
+// runBot starts the bot of the chatter, then hands it each queued response until the chatter or
+// the server shuts down.
+func (c *Chatter) runBot(done chan bool) {
+	defer c.cMngr.wg.Done()
+	defer c.wg.Done()
+	b := &BotChatter{c}
+	c.bot.Start(b)
+	for {
+		select {
+		case <-done: // Server shutdown signal.
+			return
+		case <-c.done: // Chatter shutdown signal.
+			return
+		case rsp := <-c.rspq:
+			c.mu.Lock()
+			c.lastRsp = time.Now()
+			c.rspCount++
+			c.mu.Unlock()
+			c.bot.Receive(b, rsp)
+		}
+	}
+}
+
+// isBot validates whether the chatter is a bot running inside the server.
+func (c *Chatter) isBot() bool {
+	return c.bot != nil
+}
+
+// listName returns the nickname of the chatter as shown in room member lists.
+func (c *Chatter) listName() string {
+	if c.isBot() {
+		return c.Nickname() + chatterBotMarker
+	}
+	return c.Nickname()
+}
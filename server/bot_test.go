@@ -0,0 +1,99 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// tTestBot joins a room on start, optionally echoes messages from others and records every
+// response.
+type tTestBot struct {
+	room string
+	echo bool
+	rsps chan *ChatResponse
+}
+
+func (b *tTestBot) Start(c *BotChatter) {
+	c.Join(b.room)
+}
+
+func (b *tTestBot) Receive(c *BotChatter, rsp *ChatResponse) {
+	if b.echo && rsp.RspType == ChatRspTypeMsg && rsp.Message.Nickname != c.Nickname() {
+		c.Post(b.room, "echo "+rsp.Message.Text)
+	}
+	b.rsps <- rsp
+}
+
+// tTestBotExpect waits for a response of a type to a bot and returns it.
+func tTestBotExpect(t *testing.T, b *tTestBot, rspt int) *ChatResponse {
+	for {
+		select {
+		case rsp := <-b.rsps:
+			if rsp.RspType == rspt {
+				return rsp
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Bot did not receive response type %d.", rspt)
+			return nil
+		}
+	}
+}
+
+func TestBots(t *testing.T) {
+	t.Parallel()
+	m := ChatManagerNew(0, 0, 0, "", ChatLoggerNew())
+	defer m.shutdownAll()
+	dice := &tTestBot{room: "BotRoom", rsps: make(chan *ChatResponse, 100)}
+	echo := &tTestBot{room: "BotRoom", echo: true, rsps: make(chan *ChatResponse, 100)}
+	if err := m.addBot("", dice); err != chatManagerErrNicknameMandatory {
+		t.Errorf("Bot without a nickname should not be added. Actual: %v", err)
+	}
+	if err := m.addBot("Dice", dice); err != nil {
+		t.Fatalf("Bot should be added: %v", err)
+	}
+	if err := m.addBot("Dice", echo); err != chatManagerErrNicknameUsed {
+		t.Errorf("Bot using a nickname in use should not be added. Actual: %v", err)
+	}
+	tTestBotExpect(t, dice, ChatRspTypeJoin)
+	if err := m.addBot("Echo", echo); err != nil {
+		t.Fatalf("Bot should be added: %v", err)
+	}
+	rsp := tTestBotExpect(t, dice, ChatRspTypeJoin)
+	sort.Strings(rsp.List)
+	if strings.Join(rsp.List, ",") != "Dice [bot],Echo [bot]" {
+		t.Errorf("Member list should mark bots. Actual: %v", rsp.List)
+	}
+
+	c, _ := m.findChatter("Dice")
+	(&BotChatter{c}).Post("BotRoom", "4")
+	if rsp := tTestBotExpect(t, echo, ChatRspTypeMsg); rsp.Content != "Dice: 4" {
+		t.Errorf("Bot should receive messages of the room. Actual: %s", rsp.Content)
+	}
+	if rsp := tTestBotExpect(t, dice, ChatRspTypeMsg); rsp.Content != "Dice: 4" {
+		t.Errorf("Bot should receive its own messages. Actual: %s", rsp.Content)
+	}
+	if rsp := tTestBotExpect(t, dice, ChatRspTypeMsg); rsp.Content != "Echo: echo 4" {
+		t.Errorf("Bot should post through the room. Actual: %s", rsp.Content)
+	}
+	if st := c.ChatterStatsNew(); !st.Bot || st.RemoteAddr != "" || st.ReqCount != 2 {
+		t.Errorf("Bot stats incorrect. Actual: %+v", st)
+	}
+
+	if err := m.removeBot("Echo"); err != nil {
+		t.Errorf("Bot should be removed: %v", err)
+	}
+	if rsp := tTestBotExpect(t, dice, ChatRspTypeLeave); strings.Join(rsp.List, ",") != "Dice [bot]" {
+		t.Errorf("Removed bot should leave its rooms. Actual: %v", rsp.List)
+	}
+	if err := m.removeBot("Echo"); err != chatManagerErrNicknameNotFound {
+		t.Errorf("Unknown bot should not be removed. Actual: %v", err)
+	}
+
+	m.shutdownAll()
+	tTestBotExpect(t, dice, ChatRspTypeJoin)
+	if _, err := m.findChatter("Dice"); err != nil {
+		t.Errorf("Bot should be started again after a shutdown: %v", err)
+	}
+}
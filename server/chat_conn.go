@@ -0,0 +1,239 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+var chatConnErrNoHijack = errors.New("response writer cannot be hijacked")
+
+// livenessLimits are the ping and write timeouts of each chatter. Zeros are no limit.
+type livenessLimits struct {
+	ping  time.Duration // The time between pings.
+	pong  time.Duration // The time allowed to answer a ping.
+	write time.Duration // The time a send may block.
+}
+
+// chatConnKey is the request context key of the connection of a chat client.
+type chatConnKey struct{}
+
+// chatConn wraps the network connection of a chat client to record when data arrives after a
+// ping. The websocket package discards pongs itself, so the frames read are followed here to find
+// the pong echoing the payload of the last ping, which gives the round trip time.
+type chatConn struct {
+	net.Conn
+	buffered []byte         // Data read by the http server before the connection was hijacked.
+	mu       sync.Mutex     // For locking access to the ping times.
+	frames   chatConnFrames // The frames read from the client.
+	seq      uint64         // The number of pings sent.
+	payload  []byte         // The payload of the last ping not yet echoed in a pong.
+	pinged   time.Time      // When the last ping was sent.
+	answered time.Time      // When data was first received after the last ping.
+	rtt      time.Duration  // The round trip time of the last ping echoed.
+	claims   *authClaims    // The token claims verified in the handshake, if authenticated.
+}
+
+// Read reads data from the connection, noting the first data and the pong after a ping.
+func (cc *chatConn) Read(b []byte) (int, error) {
+	var n int
+	var err error
+	if len(cc.buffered) > 0 {
+		n = copy(b, cc.buffered)
+		cc.buffered = cc.buffered[n:]
+	} else {
+		n, err = cc.Conn.Read(b)
+	}
+	if n > 0 {
+		now := time.Now()
+		cc.mu.Lock()
+		if !cc.pinged.IsZero() && cc.answered.IsZero() {
+			cc.answered = now
+		}
+		cc.frames.scan(b[:n], func(p []byte) {
+			if len(cc.payload) > 0 && bytes.Equal(p, cc.payload) {
+				cc.rtt = now.Sub(cc.pinged)
+				cc.payload = nil
+			}
+		})
+		cc.mu.Unlock()
+	}
+	return n, err
+}
+
+// ping notes a ping is being sent and returns the payload to send, which the pong echoes.
+func (cc *chatConn) ping() []byte {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.seq++
+	cc.payload = make([]byte, 8)
+	binary.BigEndian.PutUint64(cc.payload, cc.seq)
+	cc.pinged = time.Now()
+	cc.answered = time.Time{}
+	return cc.payload
+}
+
+// pong validates whether the client has sent anything since the last ping.
+func (cc *chatConn) pong() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return !cc.pinged.IsZero() && !cc.answered.IsZero()
+}
+
+// roundTrip returns the round trip time of the last ping echoed. A nil connection has none.
+func (cc *chatConn) roundTrip() time.Duration {
+	if cc == nil {
+		return 0
+	}
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.rtt
+}
+
+// verified returns the token claims verified in the handshake, if any. A nil connection has none.
+func (cc *chatConn) verified() *authClaims {
+	if cc == nil {
+		return nil
+	}
+	return cc.claims
+}
+
+// chatConnFrames follows the websocket frames read from a client to find the pongs among them.
+type chatConnFrames struct {
+	header  []byte  // The header of the frame being read, until it is complete.
+	body    bool    // Is the payload of the frame being read?
+	pong    bool    // Is the frame being read a pong?
+	left    uint64  // The payload bytes of the frame still to be read.
+	mask    [4]byte // The masking key of the frame.
+	off     int     // The payload bytes of the frame read so far.
+	payload []byte  // The unmasked payload of the pong being read.
+}
+
+// scan follows the data read from the client, calling f with the payload of each pong completed.
+func (fr *chatConnFrames) scan(b []byte, f func([]byte)) {
+	for len(b) > 0 {
+		if !fr.body {
+			fr.header = append(fr.header, b[0])
+			b = b[1:]
+			if len(fr.header) == chatConnHeaderLen(fr.header) {
+				fr.start()
+				fr.end(f)
+			}
+			continue
+		}
+		n := len(b)
+		if uint64(n) > fr.left {
+			n = int(fr.left)
+		}
+		if fr.pong {
+			for _, c := range b[:n] {
+				fr.payload = append(fr.payload, c^fr.mask[fr.off%4])
+				fr.off++
+			}
+		}
+		fr.left -= uint64(n)
+		b = b[n:]
+		fr.end(f)
+	}
+}
+
+// start begins the payload of the frame whose header is complete.
+func (fr *chatConnFrames) start() {
+	h := fr.header
+	fr.pong = h[0]&0x0f == websocket.PongFrame
+	fr.left = uint64(h[1] & 0x7f)
+	n := 2
+	switch fr.left {
+	case 126:
+		fr.left, n = uint64(binary.BigEndian.Uint16(h[2:])), 4
+	case 127:
+		fr.left, n = binary.BigEndian.Uint64(h[2:]), 10
+	}
+	fr.mask = [4]byte{}
+	if h[1]&0x80 != 0 {
+		copy(fr.mask[:], h[n:])
+	}
+	fr.header, fr.body, fr.off, fr.payload = fr.header[:0], true, 0, fr.payload[:0]
+}
+
+// end finishes the frame once its payload is read, calling f with the payload of a pong.
+func (fr *chatConnFrames) end(f func([]byte)) {
+	if !fr.body || fr.left > 0 {
+		return
+	}
+	fr.body = false
+	if fr.pong {
+		f(fr.payload)
+	}
+}
+
+// chatConnHeaderLen returns the length of a frame header from its first bytes.
+func chatConnHeaderLen(h []byte) int {
+	if len(h) < 2 {
+		return 2
+	}
+	n := 2
+	switch h[1] & 0x7f {
+	case 126:
+		n += 2
+	case 127:
+		n += 8
+	}
+	if h[1]&0x80 != 0 {
+		n += 4
+	}
+	return n
+}
+
+// chatConnWriter is a response writer that wraps the connection it hands over on a hijack.
+type chatConnWriter struct {
+	http.ResponseWriter
+	cc *chatConn // The wrapper of the hijacked connection.
+}
+
+// Hijack takes over the connection of the response for the websocket, wrapped for ping tracking.
+func (w *chatConnWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, chatConnErrNoHijack
+	}
+	rwc, buf, err := h.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	if n := buf.Reader.Buffered(); n > 0 {
+		b, _ := buf.Reader.Peek(n)
+		w.cc.buffered = append([]byte(nil), b...)
+	}
+	w.cc.Conn = rwc
+	return w.cc, bufio.NewReadWriter(bufio.NewReader(w.cc), buf.Writer), nil
+}
+
+// chatConnHandler wraps a websocket handler so the connection of each chat client is tracked. The
+// connection is found from the websocket with chatConnFrom.
+func chatConnHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cc := &chatConn{}
+		h.ServeHTTP(&chatConnWriter{w, cc}, r.WithContext(context.WithValue(r.Context(), chatConnKey{}, cc)))
+	})
+}
+
+// chatConnFrom returns the tracked connection of a websocket, or nil if it is not tracked.
+func chatConnFrom(ws *websocket.Conn) *chatConn {
+	if ws == nil || ws.Request() == nil {
+		return nil
+	}
+	cc, _ := ws.Request().Context().Value(chatConnKey{}).(*chatConn)
+	if cc == nil || cc.Conn == nil {
+		return nil
+	}
+	return cc
+}
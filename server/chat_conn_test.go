@@ -0,0 +1,84 @@
+package server
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// tTestFrame returns a masked websocket frame as sent by a client.
+func tTestFrame(opcode byte, payload []byte) []byte {
+	mask := []byte{1, 2, 3, 4}
+	f := append([]byte{0x80 | opcode, 0x80 | byte(len(payload))}, mask...)
+	for i, c := range payload {
+		f = append(f, c^mask[i%4])
+	}
+	return f
+}
+
+func TestChatConnPong(t *testing.T) {
+	t.Parallel()
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	text := tTestFrame(websocket.TextFrame, []byte("hi"))
+	cc := &chatConn{Conn: a, buffered: text[:3]}
+	write := func(f []byte, n int) {
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			b.Write(f)
+		}()
+		if _, err := io.ReadFull(cc, make([]byte, n)); err != nil {
+			t.Fatalf("Read error: %s", err)
+		}
+	}
+	write(text[3:], len(text))
+	if cc.pong() {
+		t.Errorf("Pong should not be recorded before a ping.")
+	}
+	payload := cc.ping()
+	if cc.pong() {
+		t.Errorf("Pong should not be recorded before data is received.")
+	}
+	write(text, len(text))
+	if !cc.pong() || cc.roundTrip() != 0 {
+		t.Errorf("Data other than a pong should not be taken as the round trip. Actual: %s", cc.roundTrip())
+	}
+	other := tTestFrame(websocket.PongFrame, []byte("other"))
+	write(other, len(other))
+	if cc.roundTrip() != 0 {
+		t.Errorf("Pong not echoing the ping should not be taken as the round trip. Actual: %s", cc.roundTrip())
+	}
+	echo := tTestFrame(websocket.PongFrame, payload)
+	write(echo, len(echo))
+	if cc.roundTrip() < 10*time.Millisecond {
+		t.Errorf("Pong should be recorded with the round trip time. Actual: %s", cc.roundTrip())
+	}
+	var none *chatConn
+	if none.roundTrip() != 0 {
+		t.Errorf("Nil connection should have no round trip time.")
+	}
+}
+
+func TestChatConnHandler(t *testing.T) {
+	t.Parallel()
+	var found *chatConn
+	h := chatConnHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		found, _ = r.Context().Value(chatConnKey{}).(*chatConn)
+		if _, _, err := w.(http.Hijacker).Hijack(); err != chatConnErrNoHijack {
+			t.Errorf("Hijack of a writer that cannot be hijacked should fail. Actual: %v", err)
+		}
+	}))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", wsRouteV1Conn, nil))
+	if found == nil {
+		t.Errorf("Connection should be passed in the request context.")
+	}
+	if chatConnFrom(nil) != nil {
+		t.Errorf("Nil socket should have no connection.")
+	}
+}
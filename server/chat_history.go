@@ -0,0 +1,231 @@
+package server
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+var (
+	maxChatHistoryLine = 1024 * 1024 // The maximum size of one record in the history log.
+	maxChatHistoryPage = 100         // The maximum number of messages returned in one page.
+)
+
+// ChatHistory represents the persistent log of messages posted to a chat room.
+type ChatHistory struct {
+	mu     sync.RWMutex       // Lock for update.
+	dir    string             // The directory where the history logs are stored.
+	name   string             // The name of the room the history belongs to.
+	max    int                // The maximum number of messages kept for replay.
+	recent []*ChatMessage     // The most recent messages kept for replay.
+	index  []chatHistoryEntry // The ID and place in the log of each message, oldest first.
+	lastID uint64             // The ID of the last message in the log.
+	closed bool               // Is the log removed, so nothing more is written to it?
+}
+
+// chatHistoryEntry is where a message is in the log of a room.
+type chatHistoryEntry struct {
+	id  uint64 // The ID of the message.
+	off int64  // The offset of the record in the log.
+}
+
+// ChatHistoryNew is a factory function that returns a new history for a room. Any messages
+// previously logged for the room are loaded so they can be replayed.
+func ChatHistoryNew(dir string, name string, max int) (*ChatHistory, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	h := &ChatHistory{
+		dir:    dir,
+		name:   name,
+		max:    max,
+		recent: []*ChatMessage{},
+	}
+	if err := h.load(); err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// load reads the log of the room, indexes its messages and keeps the most recent for replay.
+func (h *ChatHistory) load() error {
+	h.recent = []*ChatMessage{}
+	h.index = []chatHistoryEntry{}
+	h.lastID = 0
+	return h.scan(0, func(m *ChatMessage, off int64) bool {
+		if m.ID > h.lastID { // IDs only increase, so anything else is damaged.
+			h.lastID = m.ID
+			h.index = append(h.index, chatHistoryEntry{m.ID, off})
+			h.keep(m)
+		}
+		return true
+	})
+}
+
+// scan calls f for each message in the log of the room from an offset, oldest first, until f
+// returns false.
+func (h *ChatHistory) scan(from int64, f func(*ChatMessage, int64) bool) error {
+	fl, err := os.Open(h.path())
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer fl.Close()
+	if _, err := fl.Seek(from, io.SeekStart); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(fl)
+	scanner.Buffer(make([]byte, 4096), maxChatHistoryLine)
+	off := from
+	for scanner.Scan() {
+		at := off
+		off += int64(len(scanner.Bytes())) + 1
+		m := &ChatMessage{}
+		if err := json.Unmarshal(scanner.Bytes(), m); err != nil || m.ID == 0 {
+			continue // Skip damaged records.
+		}
+		if !f(m, at) {
+			break
+		}
+	}
+	return scanner.Err()
+}
+
+// keep adds a message to the replay list, dropping the oldest if the maximum is reached.
+func (h *ChatHistory) keep(m *ChatMessage) {
+	h.recent = append(h.recent, m)
+	if len(h.recent) > h.max {
+		h.recent = h.recent[len(h.recent)-h.max:]
+	}
+}
+
+// append writes a message to the end of the room log.
+func (h *ChatHistory) append(m *ChatMessage) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+	f, err := os.OpenFile(h.path(), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	b, _ := json.Marshal(m)
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	h.lastID = m.ID
+	h.index = append(h.index, chatHistoryEntry{m.ID, info.Size()})
+	h.keep(m)
+	return nil
+}
+
+// page returns up to size messages older than the before ID, oldest first, and the cursor
+// to use for the next older page. A zero before ID starts from the latest message and a zero
+// cursor means there are no older messages.
+func (h *ChatHistory) page(before uint64, size int) ([]*ChatMessage, uint64, error) {
+	if size <= 0 || size > maxChatHistoryPage {
+		size = maxChatHistoryPage
+	}
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	end := len(h.index)
+	if before > 0 {
+		end = sort.Search(len(h.index), func(i int) bool { return h.index[i].id >= before })
+	}
+	start := end - size
+	if start < 0 {
+		start = 0
+	}
+	msgs := []*ChatMessage{}
+	if start == end {
+		return msgs, 0, nil
+	}
+	want := h.index[start:end]
+	err := h.scan(want[0].off, func(m *ChatMessage, off int64) bool {
+		if m.ID == want[0].id {
+			msgs = append(msgs, m)
+			want = want[1:]
+		}
+		return len(want) > 0
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	var next uint64
+	if start > 0 {
+		next = h.index[start].id
+	}
+	return msgs, next, nil
+}
+
+// lastMessageID returns the ID of the last message written to the log.
+func (h *ChatHistory) lastMessageID() uint64 {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.lastID
+}
+
+// last returns the most recent messages posted to the room, oldest first.
+func (h *ChatHistory) last() []*ChatMessage {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	msgs := make([]*ChatMessage, len(h.recent))
+	copy(msgs, h.recent)
+	return msgs
+}
+
+// rename moves the log to a new room name and reloads the messages kept for replay. A log already
+// stored under the new name, such as that of a room not loaded, is never overwritten.
+func (h *ChatHistory) rename(name string) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	newPath := chatHistoryPath(h.dir, name)
+	if _, err := os.Stat(newPath); err == nil {
+		return chatManagerErrRoomExists
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Rename(h.path(), newPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	h.name = name
+	return h.load()
+}
+
+// remove closes the log of the room and deletes it so a new room of the same name starts without
+// it. Messages the room still posts are not written.
+func (h *ChatHistory) remove() error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	h.recent = []*ChatMessage{}
+	h.index = []chatHistoryEntry{}
+	h.lastID = 0
+	if err := os.Remove(h.path()); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// path returns the file name of the log for the room.
+func (h *ChatHistory) path() string {
+	return chatHistoryPath(h.dir, h.name)
+}
+
+// chatHistoryPath returns the file name of the log for a room in a directory.
+func chatHistoryPath(dir string, name string) string {
+	return filepath.Join(dir, url.PathEscape(name)+".log")
+}
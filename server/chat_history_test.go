@@ -0,0 +1,164 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestChatHistoryAppendLoad(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "chattypantz")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	h, err := ChatHistoryNew(dir, "Room 237", 3)
+	if err != nil {
+		t.Fatalf("History should have been created. Err: %s", err)
+	}
+	if len(h.last()) != 0 {
+		t.Errorf("New history should be empty.")
+	}
+	for i := 1; i <= 5; i++ {
+		if err := h.append(ChatMessageNew(uint64(i), "ChatMonkey", fmt.Sprintf("Message %d", i))); err != nil {
+			t.Errorf("History append error: %s", err)
+		}
+	}
+	if msgs := h.last(); len(msgs) != 3 || msgs[0].Text != "Message 3" || msgs[2].Text != "Message 5" {
+		t.Errorf("History should keep the last 3 messages. Actual: %v", msgs)
+	}
+
+	// A restart should pick the backlog back up.
+	h, err = ChatHistoryNew(dir, "Room 237", 2)
+	if err != nil {
+		t.Fatalf("History should have been reloaded. Err: %s", err)
+	}
+	if msgs := h.last(); len(msgs) != 2 || msgs[0].Text != "Message 4" || msgs[1].Text != "Message 5" {
+		t.Errorf("Reloaded history should keep the last 2 messages. Actual: %v", msgs)
+	}
+	if h.lastMessageID() != 5 {
+		t.Errorf("Reloaded history should continue from the last ID. Actual: %d", h.lastMessageID())
+	}
+
+	// Renaming moves the log.
+	if err := h.rename("Room/238"); err != nil {
+		t.Errorf("History rename error: %s", err)
+	}
+	if msgs := h.last(); len(msgs) != 2 {
+		t.Errorf("Renamed history should keep its messages. Actual: %v", msgs)
+	}
+	h, _ = ChatHistoryNew(dir, "Room 237", 2)
+	if len(h.last()) != 0 {
+		t.Errorf("Old room name should have no history after rename.")
+	}
+
+	// Renaming never overwrites the log of another room.
+	h.append(ChatMessageNew(1, "ChatMonkey", "Other room"))
+	if err := h.rename("Room/238"); err != chatManagerErrRoomExists {
+		t.Errorf("Rename over an existing log should be refused. Actual: %v", err)
+	}
+	if h, _ = ChatHistoryNew(dir, "Room/238", 5); len(h.last()) != 5 {
+		t.Errorf("Existing log should be kept after a refused rename. Actual: %v", h.last())
+	}
+}
+
+func TestChatHistoryPage(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "chattypantz")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	h, _ := ChatHistoryNew(dir, "Room 237", 1)
+	for i := 1; i <= 5; i++ {
+		h.append(ChatMessageNew(uint64(i), "ChatMonkey", fmt.Sprintf("Message %d", i)))
+	}
+	msgs, next, err := h.page(0, 2)
+	if err != nil || len(msgs) != 2 || msgs[0].ID != 4 || msgs[1].ID != 5 || next != 4 {
+		t.Errorf("First page should be messages 4-5 with cursor 4. Actual: %v %d %v", msgs, next, err)
+	}
+	msgs, next, _ = h.page(next, 2)
+	if len(msgs) != 2 || msgs[0].ID != 2 || msgs[1].ID != 3 || next != 2 {
+		t.Errorf("Second page should be messages 2-3 with cursor 2. Actual: %v %d", msgs, next)
+	}
+	msgs, next, _ = h.page(next, 2)
+	if len(msgs) != 1 || msgs[0].ID != 1 || next != 0 {
+		t.Errorf("Last page should be message 1 with no cursor. Actual: %v %d", msgs, next)
+	}
+	msgs, next, _ = h.page(0, 0)
+	if len(msgs) != 5 || next != 0 {
+		t.Errorf("Default page should hold all messages. Actual: %v %d", msgs, next)
+	}
+	h, _ = ChatHistoryNew(dir, "Room 237", 0)
+	if msgs, next, _ = h.page(4, 2); len(msgs) != 2 || msgs[0].ID != 2 || msgs[1].ID != 3 || next != 2 {
+		t.Errorf("Pages should be found after reload without replay. Actual: %v %d", msgs, next)
+	}
+	if err := h.remove(); err != nil {
+		t.Errorf("History remove error: %s", err)
+	}
+	if _, err := os.Stat(h.path()); !os.IsNotExist(err) {
+		t.Errorf("History log should be removed.")
+	}
+	if msgs, _, _ = h.page(0, 0); len(msgs) != 0 || h.lastMessageID() != 0 {
+		t.Errorf("Removed history should be empty. Actual: %v", msgs)
+	}
+	h.append(ChatMessageNew(6, "ChatMonkey", "Too late"))
+	if _, err := os.Stat(h.path()); !os.IsNotExist(err) {
+		t.Errorf("Removed history should not be written again.")
+	}
+}
+
+func TestChatHistoryDamaged(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "chattypantz")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	h, _ := ChatHistoryNew(dir, "Room 237", 5)
+	log := `{"id":1,"nickname":"ChatMonkey","text":"One"}` + "\n" + `{"id":2,"nick` + "\n" +
+		`{"nickname":"ChatMonkey","text":"No ID"}` + "\n" + `{"id":4,"nickname":"ChatMonkey","text":"Four"}` + "\n"
+	ioutil.WriteFile(h.path(), []byte(log), 0644)
+	h, _ = ChatHistoryNew(dir, "Room 237", 5)
+	msgs, _, _ := h.page(0, 0)
+	if len(msgs) != 2 || msgs[0].ID != 1 || msgs[1].ID != 4 || h.lastMessageID() != 4 {
+		t.Errorf("Damaged records and records without an ID should be skipped. Actual: %v", msgs)
+	}
+}
+
+func TestChatHistoryReplay(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "chattypantz")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+
+	h, _ := ChatHistoryNew(dir, "Room 237", 2)
+	h.append(ChatMessageNew(1, "MonkeyTester", "First"))
+	h.append(ChatMessageNew(2, "MonkeyTester", "Second"))
+	r := ChatRoomNew("Room 237", h, make(chan bool), ChatLoggerNew(), &sync.WaitGroup{})
+	c := ChatterNew(ChatManagerNew(0, 0, 0, "", ChatLoggerNew()), nil, ChatLoggerNew())
+	c.nickname = "ChatMonkey"
+	req, _ := ChatRequestNew(c, "Room 237", ChatReqTypeJoin, "")
+	r.join(req)
+
+	rsp := <-c.rspq
+	if rsp.RspType != ChatRspTypeHistory || len(rsp.List) != 2 ||
+		rsp.List[0] != "MonkeyTester: First" || rsp.List[1] != "MonkeyTester: Second" {
+		t.Errorf("History should be replayed before the join. Actual: %s", rsp)
+	}
+	if len(rsp.Messages) != 2 || rsp.Messages[0].ID != 1 || rsp.Messages[1].Text != "Second" {
+		t.Errorf("History replay should carry structured messages. Actual: %s", rsp)
+	}
+	rsp = <-c.rspq
+	if rsp.RspType != ChatRspTypeJoin {
+		t.Errorf("Join should follow the history replay. Actual: %s", rsp)
+	}
+}
@@ -38,21 +38,42 @@ type sessionLogEntry struct {
 }
 
 // LogConnect is used to log request information when the client first connects to the server.
+// Tokens in the query and the Authorization header are not logged.
 func (l *ChatLogger) LogConnect(r *http.Request) {
 	if l.GetLogLevel() >= logger.Info {
+		u, uri, h := connectLogRedact(r)
 		b, _ := json.Marshal(&connectLogEntry{
 			Method:     r.Method,
-			URL:        r.URL,
+			URL:        u,
 			Proto:      r.Proto,
-			Header:     r.Header,
+			Header:     h,
 			Host:       r.Host,
 			RemoteAddr: r.RemoteAddr,
-			RequestURI: r.RequestURI,
+			RequestURI: uri,
 		})
 		l.Output(3, logger.Labels[logger.Info], `{"connected":%s}`, string(b))
 	}
 }
 
+// connectLogRedact returns the URL, request URI and header of a request without the credentials
+// they may carry.
+func connectLogRedact(r *http.Request) (*url.URL, string, http.Header) {
+	h := r.Header
+	if h.Get("Authorization") != "" {
+		h = h.Clone()
+		h.Del("Authorization")
+	}
+	u, uri := r.URL, r.RequestURI
+	if u != nil && u.Query().Get("token") != "" {
+		c := *u
+		q := c.Query()
+		q.Del("token")
+		c.RawQuery = q.Encode()
+		u, uri = &c, c.RequestURI()
+	}
+	return u, uri, h
+}
+
 // LogSession is used to record information received during the client's session.
 func (l *ChatLogger) LogSession(tp string, addr string, msg string) {
 	if l.GetLogLevel() >= logger.Info {
@@ -64,6 +85,25 @@ func (l *ChatLogger) LogSession(tp string, addr string, msg string) {
 	}
 }
 
+// adminLogEntry is a datastructure for recording the audit trail of the admin API.
+type adminLogEntry struct {
+	RemoteAddr string `json:"remoteAddr"`
+	Action     string `json:"action"`
+	Result     string `json:"result"`
+}
+
+// LogAdmin is used to record an admin API request and its result for auditing.
+func (l *ChatLogger) LogAdmin(addr string, action string, result string) {
+	if l.GetLogLevel() >= logger.Info {
+		b, _ := json.Marshal(&adminLogEntry{
+			RemoteAddr: addr,
+			Action:     action,
+			Result:     result,
+		})
+		l.Output(3, logger.Labels[logger.Info], `{"admin":%s}`, string(b))
+	}
+}
+
 // LogError is used to record misc session error information between server and client.
 func (l *ChatLogger) LogError(addr string, msg string) {
 	if l.GetLogLevel() >= logger.Error {
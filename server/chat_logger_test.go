@@ -18,8 +18,9 @@ const (
 		`"Host":"www.ladeda.com","Path":"","RawQuery":"","Fragment":""},"proto":"HTTP/1.1",` +
 		`"header":{},"host":"ladeda.com","remoteAddr":"127.8.9.10","requestURI":` +
 		`"ws://www.ladeda.com/v1.0/chat"}}`
-	testChatLogExpSess = `{"disconnected":{"remoteAddr":"127.8.9.10","message":"Client disconnected."}}`
-	testChatLogExpErr  = `{"error":{"remoteAddr":"127.8.9.10","message":"Couldn't receive. Error: Tester"}}`
+	testChatLogExpSess  = `{"disconnected":{"remoteAddr":"127.8.9.10","message":"Client disconnected."}}`
+	testChatLogExpAdmin = `{"admin":{"remoteAddr":"127.8.9.10","action":"DELETE /v1.0/admin/rooms/Room1","result":"ok"}}`
+	testChatLogExpErr   = `{"error":{"remoteAddr":"127.8.9.10","message":"Couldn't receive. Error: Tester"}}`
 )
 
 func TestLogConnect(t *testing.T) {
@@ -41,6 +42,26 @@ func TestLogConnect(t *testing.T) {
 	}, fmt.Sprintf("%s%s\n", testLbl, testChatLogExpCnt))
 }
 
+func TestLogConnectRedact(t *testing.T) {
+	t.Parallel()
+	u, _ := url.Parse("http://www.ladeda.com/v1.0/chat?token=secret&x=1")
+	r := &http.Request{
+		URL:        u,
+		Header:     http.Header{"Authorization": {"Bearer secret"}, "Origin": {"http://ladeda.com"}},
+		RequestURI: "/v1.0/chat?token=secret&x=1",
+	}
+	lu, uri, h := connectLogRedact(r)
+	if lu.RawQuery != "x=1" || uri != "/v1.0/chat?x=1" {
+		t.Errorf("Token should be removed from the query. Actual: %s %s", lu, uri)
+	}
+	if h.Get("Authorization") != "" || h.Get("Origin") == "" {
+		t.Errorf("Only the Authorization header should be removed. Actual: %v", h)
+	}
+	if r.URL.RawQuery == "x=1" || r.Header.Get("Authorization") == "" {
+		t.Errorf("Request should not be changed.")
+	}
+}
+
 func TestLogSession(t *testing.T) {
 	t.Parallel()
 	testLbl := logger.Labels[logger.Info]
@@ -50,6 +71,14 @@ func TestLogSession(t *testing.T) {
 	}, fmt.Sprintf("%s%s\n", testLbl, testChatLogExpSess))
 }
 
+func TestLogAdmin(t *testing.T) {
+	testLbl := logger.Labels[logger.Info]
+	expectOutput(t, func() {
+		l := ChatLoggerNew()
+		l.LogAdmin("127.8.9.10", "DELETE /v1.0/admin/rooms/Room1", "ok")
+	}, fmt.Sprintf("%s%s\n", testLbl, testChatLogExpAdmin))
+}
+
 func TestLogError(t *testing.T) {
 	t.Parallel()
 	testLbl := logger.Labels[logger.Error]
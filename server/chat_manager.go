@@ -2,25 +2,56 @@ package server
 
 import (
 	"errors"
+	"fmt"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/net/websocket"
 )
 
 var (
+	chatManagerDrainPoll = 100 * time.Millisecond // How often queues are checked while draining.
+
 	chatManagerErrMaxRooms     = errors.New("maximum number of rooms reached")
 	chatManagerErrRoomExists   = errors.New("room already exists")
 	chatManagerErrRoomNotEmpty = errors.New("room is not empty")
 	chatManagerErrRoomNotFound = errors.New("chatroom not found")
+
+	chatManagerErrNicknameMandatory = errors.New("nickname is mandatory")
+	chatManagerErrNicknameNotFound  = errors.New("nickname not found")
+	chatManagerErrNicknameAmbiguous = errors.New("nickname is used by more than one chatter")
+	chatManagerErrNicknameUsed      = errors.New("nickname is already in use")
+	chatManagerErrNicknameBanned    = errors.New("nickname is banned from the server")
 )
 
 // ChatManager represents a control hub of chat rooms and chatters for the server.
 type ChatManager struct {
-	mu       sync.RWMutex         // Lock for update.
-	rooms    map[string]*ChatRoom // A list of rooms on the server.
-	chatters map[*Chatter]bool    // A list of chatters on the server.
-	maxRooms int                  // Maximum number of rooms allowed to be created.
-	maxIdle  int                  // Maximum idle time allowed for a ws connection.
+	mu         sync.RWMutex             // Lock for update.
+	rooms      map[string]*ChatRoom     // A list of rooms on the server.
+	chatters   map[*Chatter]bool        // A list of chatters on the server.
+	bots       map[string]*Chatter      // The chatters of bots running inside the server by nickname.
+	maxRooms   int                      // Maximum number of rooms allowed to be created.
+	maxIdle    int                      // Maximum idle time allowed for a ws connection.
+	maxHist    int                      // Maximum number of messages replayed to a joining chatter.
+	histDir    string                   // Directory where room history logs are stored.
+	admins     map[string]bool          // Nicknames allowed to administer any room once proven.
+	nicks      *NicknameRegistry        // Nicknames registered with a password.
+	unique     bool                     // Must nicknames be unique across the server?
+	bans       map[string]bool          // Nicknames and IPs banned from the server.
+	motd       string                   // The message of the day sent to each chatter on connect.
+	limits     rateLimits               // The request rate limits of each chatter.
+	sizes      sizeLimits               // The request size limits of each chatter.
+	filters    []MessageFilter          // Built-in filters of posted messages enabled by the options.
+	added      []MessageFilter          // Filters of posted messages added by the embedding program.
+	commands   map[string]*SlashCommand // Slash commands chatters may type into messages by name.
+	typingTTL  int                      // Seconds before a typing notice stops by itself.
+	typingIdle bool                     // Do typing notices reset the idle timeout of a chatter?
+	awayIdle   int                      // Seconds idle before a chatter is shown as away.
+	live       livenessLimits           // The ping and write timeouts of each chatter.
+	throttled  uint64                   // Total requests dropped by rate limits.
+	floodKicks uint64                   // Total chatters disconnected for exceeding rate limits.
 
 	done chan bool      // Shut down chatters and rooms
 	log  *ChatLogger    // Application log for events.
@@ -28,12 +59,20 @@ type ChatManager struct {
 }
 
 // ChatManagerNew is a factory function that returns a new instance of a chat manager.
-func ChatManagerNew(maxr int, maxi int, l *ChatLogger) *ChatManager {
+func ChatManagerNew(maxr int, maxi int, maxh int, hdir string, l *ChatLogger) *ChatManager {
+	nicks, _ := NicknameRegistryNew("")
 	return &ChatManager{
 		rooms:    make(map[string]*ChatRoom),
 		chatters: make(map[*Chatter]bool),
+		bots:     make(map[string]*Chatter),
 		maxRooms: maxr,
 		maxIdle:  maxi,
+		maxHist:  maxh,
+		histDir:  hdir,
+		admins:   make(map[string]bool),
+		nicks:    nicks,
+		bans:     make(map[string]bool),
+		commands: slashCommandsNew(),
 		done:     make(chan bool),
 		log:      l,
 	}
@@ -61,13 +100,18 @@ func (m *ChatManager) find(name string) (*ChatRoom, error) {
 	return rm, nil
 }
 
-// findCreate returns a chat room for a given name or create a new one.
-func (m *ChatManager) findCreate(name string) (*ChatRoom, error) {
+// findCreate returns a chat room for a given name or create a new one owned by the chatter.
+func (m *ChatManager) findCreate(name string, owner *Chatter) (*ChatRoom, error) {
 	room, err := m.find(name)
 	if err == nil {
 		return room, nil
 	}
-	return m.createRoom(name)
+	room, err = m.createRoom(name)
+	if err != nil {
+		return nil, err
+	}
+	room.SetOwner(owner)
+	return room, nil
 }
 
 // createRoom returns a new chat room,
@@ -82,7 +126,14 @@ func (m *ChatManager) createRoom(name string) (*ChatRoom, error) {
 		m.mu.Unlock()
 		return nil, chatManagerErrMaxRooms
 	}
-	room := ChatRoomNew(name, m.done, m.log, &m.wg)
+	var hist *ChatHistory
+	if m.histDir != "" { // Every message is kept; the maximum is only how many are replayed.
+		if hist, err = ChatHistoryNew(m.histDir, name, m.maxHist); err != nil {
+			m.log.Errorf("Cannot load history for room \"%s\": %s", name, err.Error())
+			hist = nil
+		}
+	}
+	room := ChatRoomNew(name, hist, m.done, m.log, &m.wg)
 	m.rooms[name] = room
 	m.wg.Add(1)
 	go room.Run()
@@ -90,8 +141,9 @@ func (m *ChatManager) createRoom(name string) (*ChatRoom, error) {
 	return room, nil
 }
 
-// renameRoom is used to change the name of a room.
-func (m *ChatManager) renameRoom(oldName string, newName string) error {
+// renameRoom is used to change the name of a room. The room must be empty but for the chatter
+// asking, if any.
+func (m *ChatManager) renameRoom(oldName string, newName string, by *Chatter) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	room, ok := m.rooms[oldName]
@@ -102,9 +154,16 @@ func (m *ChatManager) renameRoom(oldName string, newName string) error {
 	if ok {
 		return chatManagerErrRoomExists
 	}
-	if !room.isEmpty() {
+	if !room.isEmptyBut(by) {
 		return chatManagerErrRoomNotEmpty
 	}
+	if room.history != nil { // The history follows the room to the new name.
+		if err := room.history.rename(newName); err == chatManagerErrRoomExists {
+			return err
+		} else if err != nil {
+			m.log.Errorf("Cannot rename history for room \"%s\": %s", newName, err.Error())
+		}
+	}
 
 	delete(m.rooms, oldName)
 	room.SetName(newName)
@@ -112,19 +171,25 @@ func (m *ChatManager) renameRoom(oldName string, newName string) error {
 	return nil
 }
 
-// deleteRoom stops a chat room from running and removes it from the directory.
-func (m *ChatManager) deleteRoom(name string) error {
+// deleteRoom stops a chat room from running and removes it from the directory. The room must be
+// empty but for the chatter asking, if any.
+func (m *ChatManager) deleteRoom(name string, by *Chatter) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 	room, ok := m.rooms[name]
 	if !ok {
 		return chatManagerErrRoomNotFound
 	}
-	if !room.isEmpty() {
+	if !room.isEmptyBut(by) {
 		return chatManagerErrRoomNotEmpty
 	}
 	delete(m.rooms, name)
 	close(room.reqq)
+	if room.history != nil {
+		if err := room.history.remove(); err != nil {
+			m.log.Errorf("Cannot remove history for room \"%s\": %s", name, err.Error())
+		}
+	}
 	return nil
 }
 
@@ -150,6 +215,32 @@ func (m *ChatManager) getRoomStats() []*ChatRoomStats {
 	return stats
 }
 
+// roomMetrics returns a snapshot of the counters of each room, sorted by name.
+func (m *ChatManager) roomMetrics() []*chatRoomMetrics {
+	m.mu.RLock()
+	var metrics []*chatRoomMetrics
+	for _, r := range m.rooms {
+		metrics = append(metrics, r.metrics())
+	}
+	m.mu.RUnlock()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].name < metrics[j].name })
+	return metrics
+}
+
+// chatterMetrics returns the number of chatters with their total requests, responses and queued
+// responses.
+func (m *ChatManager) chatterMetrics() (count int, reqs uint64, rsps uint64, queued int) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for c := range m.chatters {
+		st := c.ChatterStatsNew()
+		reqs += st.ReqCount
+		rsps += st.RspCount
+		queued += len(c.rspq)
+	}
+	return len(m.chatters), reqs, rsps, queued
+}
+
 // registerChatter registers a new chatter with the chat manager.
 func (m *ChatManager) registerNewChatter(ws *websocket.Conn) *Chatter {
 	m.mu.Lock()
@@ -159,6 +250,173 @@ func (m *ChatManager) registerNewChatter(ws *websocket.Conn) *Chatter {
 	return chatr
 }
 
+// addBot connects a bot to the server as a chatter using the nickname, which must not be banned or
+// used by any other chatter.
+func (m *ChatManager) addBot(name string, b Bot) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if name == "" {
+		return chatManagerErrNicknameMandatory
+	}
+	if m.bans[name] {
+		return chatManagerErrNicknameBanned
+	}
+	for c := range m.chatters {
+		if c.Nickname() == name {
+			return chatManagerErrNicknameUsed
+		}
+	}
+	m.startBot(name, b)
+	return nil
+}
+
+// startBot creates the chatter of a bot and runs the bot in the background. The caller must hold
+// the lock.
+func (m *ChatManager) startBot(name string, b Bot) {
+	c := ChatterNew(m, nil, m.log)
+	c.bot = b
+	c.nickname = name
+	c.locked = true
+	c.start = time.Now()
+	m.chatters[c] = true
+	m.bots[name] = c
+	m.wg.Add(1)
+	c.wg.Add(1)
+	go c.runBot(m.done)
+}
+
+// removeBot disconnects the bot using the nickname and removes it from its rooms.
+func (m *ChatManager) removeBot(name string) error {
+	m.mu.Lock()
+	c, ok := m.bots[name]
+	if ok {
+		delete(m.bots, name)
+		delete(m.chatters, c)
+	}
+	m.mu.Unlock()
+	if !ok {
+		return chatManagerErrNicknameNotFound
+	}
+	c.shutDown()
+	return nil
+}
+
+// findChatter returns the one chatter on the server using a nickname.
+func (m *ChatManager) findChatter(name string) (*Chatter, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	var found *Chatter
+	for c := range m.chatters {
+		if c.Nickname() != name {
+			continue
+		}
+		if found != nil {
+			return nil, chatManagerErrNicknameAmbiguous
+		}
+		found = c
+	}
+	if found == nil {
+		return nil, chatManagerErrNicknameNotFound
+	}
+	return found, nil
+}
+
+// renameChatter changes the nickname of a chatter and tells every room the chatter is in. The
+// nickname must not be used by another member of those rooms or, in unique nickname mode, by any
+// chatter on the server. The room in conflict, if any, is returned with the error.
+func (m *ChatManager) renameChatter(c *Chatter, name string) (*ChatRoom, error) {
+	old := c.Nickname()
+	rooms, r, err := m.setChatterName(c, name)
+	if err != nil {
+		return r, err
+	}
+	for _, r := range rooms {
+		r.renameMember(c, old, name)
+	}
+	return nil, nil
+}
+
+// setChatterName validates and sets the new nickname of a chatter, returning the rooms it is in so
+// the rename is announced after the lock is released. On a clash in a room the room is returned.
+func (m *ChatManager) setChatterName(c *Chatter, name string) ([]*ChatRoom, *ChatRoom, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if name == c.Nickname() {
+		return nil, nil, nil
+	}
+	if m.bans[name] {
+		return nil, nil, chatManagerErrNicknameBanned
+	}
+	if m.nicknameUsed(c, name) {
+		return nil, nil, chatManagerErrNicknameUsed
+	}
+	var rooms []*ChatRoom
+	for _, r := range m.rooms {
+		if !r.isMember(c) {
+			continue
+		}
+		if o := r.memberByName(name); o != nil && o != c {
+			return nil, r, chatManagerErrNicknameUsed
+		}
+		rooms = append(rooms, r)
+	}
+	c.setName(name)
+	return rooms, nil, nil
+}
+
+// lockChatter locks the nickname of a chatter to the identity it authenticated as. In unique
+// nickname mode no other chatter may be using the nickname.
+func (m *ChatManager) lockChatter(c *Chatter, name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.nicknameUsed(c, name) {
+		return chatManagerErrNicknameUsed
+	}
+	c.lockNickname(name)
+	return nil
+}
+
+// nicknameUsed validates whether, in unique nickname mode, another chatter uses the nickname.
+// The caller must hold the lock.
+func (m *ChatManager) nicknameUsed(c *Chatter, name string) bool {
+	if !m.unique {
+		return false
+	}
+	for o := range m.chatters {
+		if o != c && o.Nickname() == name {
+			return true
+		}
+	}
+	return false
+}
+
+// proveChatter moves the room roles a chatter holds by its connection to the identity it has
+// proven.
+func (m *ChatManager) proveChatter(c *Chatter) {
+	m.mu.RLock()
+	var rooms []*ChatRoom
+	for _, r := range m.rooms {
+		rooms = append(rooms, r)
+	}
+	m.mu.RUnlock()
+	for _, r := range rooms {
+		r.proveMember(c)
+	}
+}
+
+// presenceChanged tells every room the chatter is in of its presence.
+func (m *ChatManager) presenceChanged(c *Chatter) {
+	m.mu.RLock()
+	var rooms []*ChatRoom
+	for _, r := range m.rooms {
+		rooms = append(rooms, r)
+	}
+	m.mu.RUnlock()
+	for _, r := range rooms {
+		r.presenceChanged(c)
+	}
+}
+
 // getChatterStats returns statistics from all chatters
 func (m *ChatManager) getChatterStats() []*ChatterStats {
 	m.mu.RLock()
@@ -179,16 +437,322 @@ func (m *ChatManager) unregisterChatter(c *Chatter) {
 	}
 }
 
-// Shuts down the chatters and the rooms. Used by server on quit.
+// drain tells every chatter the server is shutting down, then waits until the request queues of
+// the rooms and the response queues of the chatters are empty or the grace period is over.
+func (m *ChatManager) drain(reconnect int, grace time.Duration) {
+	m.mu.RLock()
+	var chatters []*Chatter
+	for c := range m.chatters {
+		chatters = append(chatters, c)
+	}
+	m.mu.RUnlock()
+	for _, c := range chatters {
+		c.sendResponse("", ChatRspTypeShutdown,
+			fmt.Sprintf("Server shutting down, reconnect in %d seconds.", reconnect), nil)
+	}
+	deadline := time.Now().Add(grace)
+	for time.Now().Before(deadline) {
+		time.Sleep(chatManagerDrainPoll) // Also lets the last response taken off a queue be sent.
+		if m.isDrained() {
+			return
+		}
+	}
+	m.log.Warningf("Grace period over before all queues were drained.")
+}
+
+// isDrained validates whether the request queues of the rooms and the response queues of the
+// chatters are empty.
+func (m *ChatManager) isDrained() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	for _, r := range m.rooms {
+		if len(r.reqq) > 0 {
+			return false
+		}
+	}
+	for c := range m.chatters {
+		if len(c.rspq) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Shuts down the chatters and the rooms. Used by server on quit. The manager may be used again
+// afterwards, with the bots connected again.
 func (m *ChatManager) shutdownAll() {
 	close(m.done)
 	m.wg.Wait()
 	m.mu.Lock()
+	bots := m.bots
 	m.rooms = make(map[string]*ChatRoom)
 	m.chatters = make(map[*Chatter]bool)
+	m.bots = make(map[string]*Chatter)
+	m.done = make(chan bool)
+	for name, c := range bots { // Bots are started again to rejoin their rooms.
+		m.startBot(name, c.bot)
+	}
 	m.mu.Unlock()
 }
 
+// isAdmin validates whether a nickname may administer any room on the server.
+func (m *ChatManager) isAdmin(name string) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return name != "" && m.admins[name]
+}
+
+// SetAdmins sets the nicknames allowed to administer any room on the server once proven.
+func (m *ChatManager) SetAdmins(names []string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.admins = make(map[string]bool)
+	for _, n := range names {
+		m.admins[n] = true
+	}
+}
+
+// isBanned validates whether the nickname or IP of a chatter is banned from the server.
+func (m *ChatManager) isBanned(c *Chatter) bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	ip := c.remoteIP()
+	return m.bans[c.Nickname()] || (ip != "" && m.bans[ip])
+}
+
+// SetBans sets the nicknames and IPs banned from the server. Chatters already connected with a
+// banned nickname or IP are disconnected, and bots with a banned nickname are removed.
+func (m *ChatManager) SetBans(bans []string) {
+	m.mu.Lock()
+	m.bans = make(map[string]bool)
+	for _, b := range bans {
+		m.bans[b] = true
+	}
+	var expel []*Chatter
+	var bots []string
+	for c := range m.chatters {
+		switch ip := c.remoteIP(); {
+		case c.isBot() && m.bans[c.Nickname()]:
+			bots = append(bots, c.Nickname())
+		case m.bans[c.Nickname()] || (ip != "" && m.bans[ip]):
+			expel = append(expel, c)
+		}
+	}
+	m.mu.Unlock()
+	for _, c := range expel {
+		m.log.LogSession("disconnected", c.remoteAddr(), "Client banned from the server.")
+		c.disconnect(ChatRspTypeErrBanned, "You are banned from the server.")
+	}
+	for _, name := range bots {
+		if m.removeBot(name) == nil {
+			m.log.LogSession("disconnected", "", fmt.Sprintf(`Bot "%s" banned from the server.`, name))
+		}
+	}
+}
+
+// rateLimits returns the request rate limits of each chatter.
+func (m *ChatManager) rateLimits() rateLimits {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.limits
+}
+
+// SetRateLimits sets the messages per second, message burst, joins per minute and throttled
+// requests per minute allowed to each chatter before it is disconnected. Zeros are no limit.
+func (m *ChatManager) SetRateLimits(msgRate int, msgBurst int, joinRate int, maxThrottle int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.limits = rateLimits{msgRate, msgBurst, joinRate, maxThrottle}
+}
+
+// sizeLimits returns the request size limits of each chatter.
+func (m *ChatManager) sizeLimits() sizeLimits {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.sizes
+}
+
+// SetSizeLimits sets the maximum bytes in a request frame and characters in a message, nickname
+// and room name allowed to each chatter. Zeros are no limit.
+func (m *ChatManager) SetSizeLimits(frame int, msg int, nick int, room int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sizes = sizeLimits{frame, msg, nick, room}
+}
+
+// typingLimits returns how long a typing notice lasts without a stop, zero for no limit, and
+// whether typing notices reset the idle timeout of a chatter.
+func (m *ChatManager) typingLimits() (time.Duration, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return time.Duration(m.typingTTL) * time.Second, m.typingIdle
+}
+
+// SetTyping sets the seconds a typing notice lasts without a stop, zero for no limit, and whether
+// typing notices reset the idle timeout of a chatter.
+func (m *ChatManager) SetTyping(ttl int, resetsIdle bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.typingTTL = ttl
+	m.typingIdle = resetsIdle
+}
+
+// AwayIdle returns the seconds idle before a chatter is shown as away, zero for never.
+func (m *ChatManager) AwayIdle() int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.awayIdle
+}
+
+// SetAwayIdle sets the seconds idle before a chatter is shown as away, zero for never.
+func (m *ChatManager) SetAwayIdle(awayi int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.awayIdle = awayi
+}
+
+// livenessLimits returns the ping and write timeouts of each chatter.
+func (m *ChatManager) livenessLimits() livenessLimits {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.live
+}
+
+// SetLiveness sets the seconds between pings to each chatter, the seconds a chatter has to answer
+// a ping and the seconds a send to a chatter may block. Zeros are no limit.
+func (m *ChatManager) SetLiveness(ping int, pong int, write int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.live = livenessLimits{
+		ping:  time.Duration(ping) * time.Second,
+		pong:  time.Duration(pong) * time.Second,
+		write: time.Duration(write) * time.Second,
+	}
+}
+
+// SetMessageFilters sets the built-in filters of posted messages. They run before any filters
+// added with AddMessageFilter.
+func (m *ChatManager) SetMessageFilters(filters []MessageFilter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.filters = filters
+}
+
+// AddMessageFilter adds a filter of posted messages to run after those already registered.
+func (m *ChatManager) AddMessageFilter(f MessageFilter) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.added = append(m.added, f)
+}
+
+// filterMessage runs a posted message through the filters in order and returns the content to
+// post, or the error of the filter that rejected it.
+func (m *ChatManager) filterMessage(room string, nickname string, content string) (string, error) {
+	m.mu.RLock()
+	filters := append(append([]MessageFilter(nil), m.filters...), m.added...)
+	m.mu.RUnlock()
+	var err error
+	for _, f := range filters {
+		if content, err = f.Filter(room, nickname, content); err != nil {
+			return "", err
+		}
+	}
+	return content, nil
+}
+
+// slashCommand returns the slash command using a name, or nil if there is none.
+func (m *ChatManager) slashCommand(name string) *SlashCommand {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.commands[name]
+}
+
+// slashCommandHelp returns how each slash command is used and what it does, sorted by name.
+func (m *ChatManager) slashCommandHelp() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	help := []string{"/help - List the commands."}
+	for _, s := range m.commands {
+		help = append(help, fmt.Sprintf("%s - %s", s.usage(), s.Help))
+	}
+	sort.Strings(help)
+	return help
+}
+
+// AddSlashCommand registers a slash command, replacing any command using the same name. The name
+// is matched ignoring case and "help" is reserved.
+func (m *ChatManager) AddSlashCommand(cmd *SlashCommand) error {
+	name := strings.ToLower(cmd.Name)
+	if name == "" || name == "help" || strings.ContainsAny(name, " \t/") || cmd.Run == nil {
+		return slashCommandErrInvalid
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s := *cmd
+	s.Name = name
+	m.commands[name] = &s
+	return nil
+}
+
+// incrThrottled counts a request dropped by rate limits and whether the chatter was disconnected.
+func (m *ChatManager) incrThrottled(kicked bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.throttled++
+	if kicked {
+		m.floodKicks++
+	}
+}
+
+// throttleStats returns the total requests dropped by rate limits and chatters disconnected.
+func (m *ChatManager) throttleStats() (uint64, uint64) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.throttled, m.floodKicks
+}
+
+// MOTD returns the message of the day sent to each chatter on connect.
+func (m *ChatManager) MOTD() string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.motd
+}
+
+// SetMOTD sets the message of the day sent to each chatter on connect.
+func (m *ChatManager) SetMOTD(motd string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.motd = motd
+}
+
+// UniqueNicknames returns whether nicknames must be unique across the server.
+func (m *ChatManager) UniqueNicknames() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.unique
+}
+
+// SetUniqueNicknames sets whether nicknames must be unique across the server.
+func (m *ChatManager) SetUniqueNicknames(u bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.unique = u
+}
+
+// Nicknames returns the registry of nicknames claimed with a password.
+func (m *ChatManager) Nicknames() *NicknameRegistry {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.nicks
+}
+
+// SetNicknames sets the registry of nicknames claimed with a password.
+func (m *ChatManager) SetNicknames(r *NicknameRegistry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.nicks = r
+}
+
 // MaxRooms returns the current maximum number of rooms allowed on the server.
 func (m *ChatManager) MaxRooms() int {
 	m.mu.RLock()
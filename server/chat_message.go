@@ -0,0 +1,37 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ChatMessage is a structure for a single message posted by a chatter to a room.
+type ChatMessage struct {
+	ID       uint64    `json:"id"`       // The sequence number of the message within the room.
+	Nickname string    `json:"nickname"` // The nickname of the chatter who posted the message.
+	Time     time.Time `json:"time"`     // The server time the message was received (UTC).
+	Text     string    `json:"text"`     // The raw text of the message.
+}
+
+// ChatMessageNew is a factory function that returns a new chat message instance.
+func ChatMessageNew(id uint64, nickname string, text string) *ChatMessage {
+	return &ChatMessage{
+		ID:       id,
+		Nickname: nickname,
+		Time:     time.Now().UTC(),
+		Text:     text,
+	}
+}
+
+// formatted returns the message as it is displayed to chatters in the room.
+func (m *ChatMessage) formatted() string {
+	return fmt.Sprintf("%s: %s", m.Nickname, m.Text)
+}
+
+// String is an implentation of the Stringer interface so the structure is returned as a
+// string to fmt.Print() etc.
+func (m *ChatMessage) String() string {
+	b, _ := json.Marshal(m)
+	return string(b)
+}
@@ -0,0 +1,37 @@
+package server
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+const (
+	testChatMsgJSONResult = `{"id":42,"nickname":"ChatMonkey","time":"2006-01-02T13:24:56Z","text":"Hello you monkeys."}`
+)
+
+func TestChatMsgNew(t *testing.T) {
+	t.Parallel()
+	m := ChatMessageNew(42, "ChatMonkey", "Hello you monkeys.")
+	if m.ID != 42 || m.Nickname != "ChatMonkey" || m.Text != "Hello you monkeys." {
+		t.Errorf("Chat Message not initialized correctly: %s", m)
+	}
+	if m.Time.Location() != time.UTC {
+		t.Errorf("Chat Message time should be UTC.")
+	}
+	if m.formatted() != "ChatMonkey: Hello you monkeys." {
+		t.Errorf("Chat Message not formatted correctly: %s", m.formatted())
+	}
+}
+
+func TestChatMsgString(t *testing.T) {
+	t.Parallel()
+	m := ChatMessageNew(42, "ChatMonkey", "Hello you monkeys.")
+	m.Time, _ = time.Parse(time.RFC1123Z, "Mon, 02 Jan 2006 13:24:56 -0000")
+	m.Time = m.Time.UTC()
+	actual := fmt.Sprint(m)
+	if actual != testChatMsgJSONResult {
+		t.Errorf("Chat Message not converted to json string.\n\nExpected: %s\n\nActual: %s\n",
+			testChatMsgJSONResult, actual)
+	}
+}
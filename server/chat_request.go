@@ -3,6 +3,9 @@ package server
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -15,19 +18,56 @@ const (
 	ChatReqTypeUnhide
 	ChatReqTypeMsg
 	ChatReqTypeLeave
+	ChatReqTypeHistoryPage
+	ChatReqTypePrivateMsg
+	ChatReqTypeRenameRoom
+	ChatReqTypeDeleteRoom
+	ChatReqTypeKick
+	ChatReqTypeBan
+	ChatReqTypeUnban
+	ChatReqTypeMute
+	ChatReqTypeUnmute
+	ChatReqTypeGrantOp
+	ChatReqTypeRevokeOp
+	ChatReqTypeSetPassword
+	ChatReqTypeSetInviteOnly
+	ChatReqTypeInvite
+	ChatReqTypeRegister
+	ChatReqTypeIdentify
+	ChatReqTypeDropNickname
+	ChatReqTypeEmote
+	ChatReqTypeTopic
+	ChatReqTypeTyping
+	ChatReqTypeSetReceipts
+	ChatReqTypeAck
+	ChatReqTypeReceipts
+	ChatReqTypeSetPresence
 )
 
 // ChatRequest is a structure for commands sent for processing from the client.
 type ChatRequest struct {
-	Who      *Chatter `json:"-"`        // The chatter who is issuing the request.
-	RoomName string   `json:"roomName"` // The name of the room to receive the request.
-	ReqType  int      `json:"reqType"`  // The command type ex: join, leave, send.
-	Content  string   `json:"content"`  // Any message or text to interpret with the request.
+	Who      *Chatter `json:"-"`                  // The chatter who is issuing the request.
+	RoomName string   `json:"roomName"`           // The name of the room to receive the request.
+	ReqType  int      `json:"reqType"`            // The command type ex: join, leave, send.
+	Content  string   `json:"content"`            // Any message or text to interpret with the request.
+	Before   uint64   `json:"before,omitempty"`   // History paging: return messages older than this ID.
+	Limit    int      `json:"limit,omitempty"`    // History paging: the maximum messages to return.
+	Target   string   `json:"target,omitempty"`   // The nickname of the chatter the request is aimed at.
+	Password string   `json:"password,omitempty"` // A password to join or lock a room.
+	ReadID   uint64   `json:"readId,omitempty"`   // Receipts: the ID of the last message displayed.
+}
+
+// sizeLimits are the size limits of requests from each chatter. Zeros are no limit.
+type sizeLimits struct {
+	frame int // The maximum bytes in a request frame.
+	msg   int // The maximum characters in message content.
+	nick  int // The maximum characters in a nickname.
+	room  int // The maximum characters in a room name.
 }
 
 // ChatMessageNew is a factory method that returns a new chat room message instance.
 func ChatRequestNew(c *Chatter, room string, reqt int, cont string) (*ChatRequest, error) {
-	if reqt < ChatReqTypeSetNickname || reqt > ChatReqTypeLeave {
+	if reqt < ChatReqTypeSetNickname || reqt > ChatReqTypeSetPresence {
 		return nil, errors.New("Request Type is out of range.")
 	}
 	return &ChatRequest{
@@ -39,8 +79,56 @@ func ChatRequestNew(c *Chatter, room string, reqt int, cont string) (*ChatReques
 }
 
 // String is an implentation of the Stringer interface so the structure is returned as a
-// string to fmt.Print() etc.
+// string to fmt.Print() etc. Passwords are masked so they are never logged.
 func (r *ChatRequest) String() string {
-	b, _ := json.Marshal(r)
+	q := *r
+	if q.Password != "" {
+		q.Password = "*****"
+	}
+	b, _ := json.Marshal(&q)
 	return string(b)
 }
+
+// isPost validates whether the request posts text for other chatters to read, which is passed
+// through the message filters.
+func (r *ChatRequest) isPost() bool {
+	switch r.ReqType {
+	case ChatReqTypeMsg, ChatReqTypePrivateMsg, ChatReqTypeEmote, ChatReqTypeTopic, ChatReqTypeSetPresence:
+		return r.Content != ""
+	}
+	return false
+}
+
+// validate checks the text of the request has no control characters and is within the size
+// limits. If it is not valid the error response type and reason are returned, otherwise zero.
+// Message content may contain tabs and line breaks.
+func (r *ChatRequest) validate(l sizeLimits) (int, string) {
+	content, max := "Message", l.msg
+	switch r.ReqType {
+	case ChatReqTypeSetNickname, ChatReqTypeRegister, ChatReqTypeIdentify, ChatReqTypeDropNickname:
+		content, max = "Nickname", l.nick
+	case ChatReqTypeRenameRoom:
+		content, max = "Room name", l.room
+	}
+	fields := []struct {
+		name string
+		text string
+		max  int
+		ws   bool
+	}{
+		{"Room name", r.RoomName, l.room, false},
+		{"Nickname", r.Target, l.nick, false},
+		{content, r.Content, max, content == "Message"},
+	}
+	for _, f := range fields {
+		if max := f.max; max > 0 && utf8.RuneCountInString(f.text) > max {
+			return ChatRspTypeErrTooLong, fmt.Sprintf("%s is longer than %d characters.", f.name, max)
+		}
+		for _, c := range f.text {
+			if unicode.IsControl(c) && !(f.ws && (c == '\n' || c == '\r' || c == '\t')) {
+				return ChatRspTypeErrControlChar, fmt.Sprintf("%s contains control characters.", f.name)
+			}
+		}
+	}
+	return 0, ""
+}
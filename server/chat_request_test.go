@@ -16,7 +16,7 @@ func TestChatReqNew(t *testing.T) {
 	if err != nil {
 		t.Errorf("Chat Request new should not have returned an error for valid low type.")
 	}
-	_, err = ChatRequestNew(nil, "Room 237", ChatReqTypeLeave, "JonnyGoLucky")
+	_, err = ChatRequestNew(nil, "Room 237", ChatReqTypeSetPresence, "JonnyGoLucky")
 	if err != nil {
 		t.Errorf("Chat Request new should not have returned an error for valid high type.")
 	}
@@ -25,7 +25,7 @@ func TestChatReqNew(t *testing.T) {
 		t.Errorf("Chat Request new should have returned an error for out of range low req type.")
 	}
 
-	_, err = ChatRequestNew(nil, "Room 237", ChatReqTypeLeave+1, "JonnyGoLucky")
+	_, err = ChatRequestNew(nil, "Room 237", ChatReqTypeSetPresence+1, "JonnyGoLucky")
 	if err == nil {
 		t.Errorf("Chat Request new should not have returned an error for out of range high req type.")
 	}
@@ -40,3 +40,56 @@ func TestChatReqString(t *testing.T) {
 			testChatReqJSONResult, actual)
 	}
 }
+
+func TestChatReqStringMasksPassword(t *testing.T) {
+	t.Parallel()
+	r, _ := ChatRequestNew(nil, "Room 237", ChatReqTypeJoin, "")
+	r.Password = "secret"
+	actual := fmt.Sprint(r)
+	expected := fmt.Sprintf(`{"roomName":"Room 237","reqType":%d,"content":"","password":"*****"}`, ChatReqTypeJoin)
+	if actual != expected {
+		t.Errorf("Chat Request password not masked.\n\nExpected: %s\n\nActual: %s\n", expected, actual)
+	}
+	if r.Password != "secret" {
+		t.Errorf("Chat Request password should not be changed by String().")
+	}
+}
+
+func TestChatReqValidate(t *testing.T) {
+	t.Parallel()
+	l := sizeLimits{msg: 10, nick: 5, room: 6}
+	tests := []struct {
+		desc   string
+		req    ChatRequest
+		rspt   int
+		reason string
+	}{
+		{"Valid message", ChatRequest{RoomName: "Room1", ReqType: ChatReqTypeMsg, Content: "Hi\tall\r\n"}, 0, ""},
+		{"Multibyte message", ChatRequest{ReqType: ChatReqTypeMsg, Content: "日本語のメッセージ"}, 0, ""},
+		{"Long message", ChatRequest{ReqType: ChatReqTypeMsg, Content: "Hello world!"},
+			ChatRspTypeErrTooLong, "Message is longer than 10 characters."},
+		{"Long nickname", ChatRequest{ReqType: ChatReqTypeSetNickname, Content: "ChatMonkey"},
+			ChatRspTypeErrTooLong, "Nickname is longer than 5 characters."},
+		{"Long target", ChatRequest{ReqType: ChatReqTypeKick, Target: "ChatMonkey"},
+			ChatRspTypeErrTooLong, "Nickname is longer than 5 characters."},
+		{"Long room", ChatRequest{RoomName: "Room 237", ReqType: ChatReqTypeJoin},
+			ChatRspTypeErrTooLong, "Room name is longer than 6 characters."},
+		{"Long new room", ChatRequest{RoomName: "Room1", ReqType: ChatReqTypeRenameRoom, Content: "Room 237"},
+			ChatRspTypeErrTooLong, "Room name is longer than 6 characters."},
+		{"Control in message", ChatRequest{ReqType: ChatReqTypeMsg, Content: "Ding\a"},
+			ChatRspTypeErrControlChar, "Message contains control characters."},
+		{"Newline in nickname", ChatRequest{ReqType: ChatReqTypeSetNickname, Content: "A\nB"},
+			ChatRspTypeErrControlChar, "Nickname contains control characters."},
+		{"Control in room", ChatRequest{RoomName: "R\x00", ReqType: ChatReqTypeJoin},
+			ChatRspTypeErrControlChar, "Room name contains control characters."},
+	}
+	for _, tc := range tests {
+		rspt, reason := tc.req.validate(l)
+		if rspt != tc.rspt || reason != tc.reason {
+			t.Errorf("%s error.\nExpected: %d %s\n\nActual: %d %s\n", tc.desc, tc.rspt, tc.reason, rspt, reason)
+		}
+	}
+	if rspt, _ := (&ChatRequest{ReqType: ChatReqTypeMsg, Content: "Hello world!"}).validate(sizeLimits{}); rspt != 0 {
+		t.Errorf("Zero limits should allow any length.")
+	}
+}
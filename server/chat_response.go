@@ -15,6 +15,36 @@ const (
 	ChatRspTypeUnhide
 	ChatRspTypeMsg
 	ChatRspTypeLeave
+	ChatRspTypeHistory
+	ChatRspTypeHistoryPage
+	ChatRspTypePrivateMsg
+	ChatRspTypeRenameRoom
+	ChatRspTypeDeleteRoom
+	ChatRspTypeKick
+	ChatRspTypeBan
+	ChatRspTypeUnban
+	ChatRspTypeMute
+	ChatRspTypeUnmute
+	ChatRspTypeGrantOp
+	ChatRspTypeRevokeOp
+	ChatRspTypeSetPassword
+	ChatRspTypeSetInviteOnly
+	ChatRspTypeInvite
+	ChatRspTypeRegister
+	ChatRspTypeIdentify
+	ChatRspTypeDropNickname
+	ChatRspTypeNicknameChanged
+	ChatRspTypeMOTD
+	ChatRspTypeShutdown
+	ChatRspTypeAnnouncement
+	ChatRspTypeEmote
+	ChatRspTypeTopic
+	ChatRspTypeCommand
+	ChatRspTypeTyping
+	ChatRspTypeSetReceipts
+	ChatRspTypeReceipt
+	ChatRspTypeReceipts
+	ChatRspTypePresence
 )
 
 const (
@@ -26,6 +56,33 @@ const (
 	ChatRspTypeErrNicknameUsed
 	ChatRspTypeErrHiddenNickname
 	ChatRspTypeErrUnknownReq
+	ChatRspTypeErrHistoryDisabled
+	ChatRspTypeErrNotMember
+	ChatRspTypeErrNicknameUnknown
+	ChatRspTypeErrNicknameAmbiguous
+	ChatRspTypeErrRoomNotFound
+	ChatRspTypeErrRoomExists
+	ChatRspTypeErrRoomNotEmpty
+	ChatRspTypeErrNotAuthorized
+	ChatRspTypeErrBanned
+	ChatRspTypeErrMuted
+	ChatRspTypeErrWrongPassword
+	ChatRspTypeErrNotInvited
+	ChatRspTypeErrNicknameLocked
+	ChatRspTypeErrNicknameRegistered
+	ChatRspTypeErrNicknameNotRegistered
+	ChatRspTypeErrPasswordMandatory
+	ChatRspTypeErrDisconnected
+	ChatRspTypeErrThrottled
+	ChatRspTypeErrFrameTooLarge
+	ChatRspTypeErrTooLong
+	ChatRspTypeErrInvalidUTF8
+	ChatRspTypeErrControlChar
+	ChatRspTypeErrMessageRejected
+	ChatRspTypeErrUnknownCommand
+	ChatRspTypeErrCommand
+	ChatRspTypeErrReceiptsDisabled
+	ChatRspTypeErrMessageUnknown
 )
 
 // ChatResponse is a structure for JSON responses sent back to the client.
@@ -34,13 +91,18 @@ type ChatResponse struct {
 	RspType  int      `json:"rspType"`  // The response type ex: join, leave, send.
 	Content  string   `json:"content"`  // Any message text or other content for the client.
 	List     []string `json:"list"`     // A list of entries returned with the response.
+
+	Message  *ChatMessage      `json:"message,omitempty"`  // A structured message posted to the room.
+	Messages []*ChatMessage    `json:"messages,omitempty"` // Structured messages from the room history.
+	Receipts map[string]uint64 `json:"receipts,omitempty"` // The last message read by each nickname.
+	Presence map[string]string `json:"presence,omitempty"` // The presence of each nickname.
 }
 
 // ChatResponseNew is a factory method that returns a new chat room message instance.
 func ChatResponseNew(name string, rspt int, cont string, l []string) (*ChatResponse, error) {
 	if rspt < ChatRspTypeSetNickname ||
-		(rspt > ChatRspTypeLeave && rspt < ChatRspTypeErrRoomMandatory) ||
-		rspt > ChatRspTypeErrUnknownReq {
+		(rspt > ChatRspTypePresence && rspt < ChatRspTypeErrRoomMandatory) ||
+		rspt > ChatRspTypeErrMessageUnknown {
 		return nil, errors.New("Response Type is out of range.")
 	}
 	return &ChatResponse{
@@ -3,11 +3,15 @@ package server
 import (
 	"fmt"
 	"testing"
+	"time"
 )
 
 var (
 	testChatRspJSONResult = fmt.Sprintf(`{"roomName":"Room 237","rspType":%d,`+
 		`"content":"JonnyGoLucky","list":["One","Two"]}`, ChatRspTypeSetNickname)
+	testChatRspMsgJSONResult = fmt.Sprintf(`{"roomName":"Room 237","rspType":%d,`+
+		`"content":"JonnyGoLucky: Hi","list":[],"message":{"id":7,"nickname":"JonnyGoLucky",`+
+		`"time":"2006-01-02T13:24:56Z","text":"Hi"}}`, ChatRspTypeMsg)
 )
 
 func TestChatRspNew(t *testing.T) {
@@ -17,7 +21,7 @@ func TestChatRspNew(t *testing.T) {
 	if err != nil {
 		t.Errorf("Chat Response new should not have returned an error for valid low type.")
 	}
-	_, err = ChatResponseNew("Room 237", ChatRspTypeLeave, "JonnyGoLucky", []string{"One", "Two"})
+	_, err = ChatResponseNew("Room 237", ChatRspTypePresence, "JonnyGoLucky", []string{"One", "Two"})
 	if err != nil {
 		t.Errorf("Chat Request new should not have returned an error for valid high type.")
 	}
@@ -25,7 +29,7 @@ func TestChatRspNew(t *testing.T) {
 	if err != nil {
 		t.Errorf("Chat Response new should not have returned an error for valid low err type.")
 	}
-	_, err = ChatResponseNew("Room 237", ChatRspTypeErrUnknownReq, "JonnyGoLucky", []string{"One", "Two"})
+	_, err = ChatResponseNew("Room 237", ChatRspTypeErrMessageUnknown, "JonnyGoLucky", []string{"One", "Two"})
 	if err != nil {
 		t.Errorf("Chat Request new should have returned an error for valid high err type.")
 	}
@@ -34,7 +38,7 @@ func TestChatRspNew(t *testing.T) {
 	if err == nil {
 		t.Errorf("Chat Response new should have returned an error for invalid low type.")
 	}
-	_, err = ChatResponseNew("Room 237", ChatRspTypeLeave+1, "JonnyGoLucky", []string{"One", "Two"})
+	_, err = ChatResponseNew("Room 237", ChatRspTypePresence+1, "JonnyGoLucky", []string{"One", "Two"})
 	if err == nil {
 		t.Errorf("Chat Request new should have returned an error for invalid high type.")
 	}
@@ -42,7 +46,7 @@ func TestChatRspNew(t *testing.T) {
 	if err == nil {
 		t.Errorf("Chat Response new should have returned an error for invalid low err type.")
 	}
-	_, err = ChatResponseNew("Room 237", ChatRspTypeErrUnknownReq+1, "JonnyGoLucky", []string{"One", "Two"})
+	_, err = ChatResponseNew("Room 237", ChatRspTypeErrMessageUnknown+1, "JonnyGoLucky", []string{"One", "Two"})
 	if err == nil {
 		t.Errorf("Chat Request new should have returned an error for invalid high err type.")
 	}
@@ -57,3 +61,16 @@ func TestChatRspString(t *testing.T) {
 			testChatRspJSONResult, actual)
 	}
 }
+
+func TestChatRspMessageString(t *testing.T) {
+	t.Parallel()
+	m := ChatMessageNew(7, "JonnyGoLucky", "Hi")
+	m.Time, _ = time.Parse(time.RFC3339, "2006-01-02T13:24:56Z")
+	r, _ := ChatResponseNew("Room 237", ChatRspTypeMsg, m.formatted(), []string{})
+	r.Message = m
+	actual := fmt.Sprint(r)
+	if actual != testChatRspMsgJSONResult {
+		t.Errorf("Chat Response with message not converted to json string.\n\nExpected: %s\n\nActual: %s\n",
+			testChatRspMsgJSONResult, actual)
+	}
+}
@@ -1,8 +1,11 @@
 package server
 
 import (
+	"crypto/sha256"
+	"crypto/subtle"
 	"fmt"
 	"runtime"
+	"strconv"
 	"sync"
 	"time"
 )
@@ -11,16 +14,62 @@ var (
 	maxChatRoomReq = 1000 // The maximum number of requests in the req channel.
 )
 
+// Roles of chatters in a room, in increasing order of authority.
+const (
+	chatRoomRoleMember = iota + 1
+	chatRoomRoleOperator
+	chatRoomRoleOwner
+)
+
+// chatRoomKey is who holds a role in a room: the identity a chatter has proven, or the chatter
+// itself when its nickname is not proven so the role ends when it disconnects.
+type chatRoomKey struct {
+	ident string   // The proven identity.
+	conn  *Chatter // The chatter with no proven identity.
+}
+
+// chatRoomKeyOf returns the key a chatter holds roles by.
+func chatRoomKeyOf(c *Chatter) chatRoomKey {
+	if id := c.identity(); id != "" {
+		return chatRoomKey{ident: id}
+	}
+	return chatRoomKey{conn: c}
+}
+
+// name returns the nickname of the holder of a role.
+func (k chatRoomKey) name() string {
+	if k.conn != nil {
+		return k.conn.Nickname()
+	}
+	return k.ident
+}
+
 // ChatRoom represents a hub of chatters where messages can be exchanged.
 type ChatRoom struct {
-	mu       sync.RWMutex      // Lock against stats.
-	name     string            // The name of the room.
-	chatters map[*Chatter]bool // A list of chatters in the room and if they are hidden from view.
-	start    time.Time         // The start time of the room.
-	lastReq  time.Time         // The last request time to the room.
-	lastRsp  time.Time         // The last response time from the room.
-	reqCount uint64            // Total requests received.
-	rspCount uint64            // Total responses sent.
+	mu       sync.RWMutex           // Lock against stats.
+	name     string                 // The name of the room.
+	owner    chatRoomKey            // The chatter who created the room.
+	ops      map[chatRoomKey]bool   // The room operators.
+	bans     map[string]string      // Banned nicknames and IPs, and the IP banned along with a nickname.
+	muted    map[chatRoomKey]bool   // The chatters not allowed to post in the room.
+	password []byte                 // A hash of the password needed to join the room, if any.
+	inviteOn bool                   // Is joining the room by invitation only?
+	invited  map[chatRoomKey]bool   // The chatters invited to join the room.
+	subject  string                 // The topic of the room.
+	receipts bool                   // Are read receipts kept for the room?
+	reads    map[*Chatter]uint64    // The ID of the last message read by each member.
+	chatters map[*Chatter]bool      // A list of chatters in the room and if they are hidden from view.
+	typers   map[*Chatter]time.Time // The chatters typing in the room and when the notice expires.
+	start    time.Time              // The start time of the room.
+	lastReq  time.Time              // The last request time to the room.
+	lastRsp  time.Time              // The last response time from the room.
+	reqCount uint64                 // Total requests received.
+	rspCount uint64                 // Total responses sent.
+	history  *ChatHistory           // The message log of the room, if history is enabled.
+	lastMsg  uint64                 // The ID of the last message posted to the room.
+	msgsIn   uint64                 // Total messages posted to the room.
+	msgsOut  uint64                 // Total messages delivered to members of the room.
+	bcast    *metricsHistogram      // Latency of broadcasts to the members of the room.
 
 	reqq chan *ChatRequest // Channel to receive requests.
 	done chan bool         // Channel to receive signal to shutdown now.
@@ -29,21 +78,35 @@ type ChatRoom struct {
 }
 
 // ChatRoomNew is a factory function that returns a new instance of a chat room.
-func ChatRoomNew(name string, d chan bool, cl *ChatLogger, g *sync.WaitGroup) *ChatRoom {
-	return &ChatRoom{
+func ChatRoomNew(name string, h *ChatHistory, d chan bool, cl *ChatLogger, g *sync.WaitGroup) *ChatRoom {
+	r := &ChatRoom{
 		name:     name,
 		chatters: make(map[*Chatter]bool),
+		typers:   make(map[*Chatter]time.Time),
+		reads:    make(map[*Chatter]uint64),
+		ops:      make(map[chatRoomKey]bool),
+		bans:     make(map[string]string),
+		muted:    make(map[chatRoomKey]bool),
+		invited:  make(map[chatRoomKey]bool),
+		history:  h,
+		bcast:    metricsHistogramNew(metricsBroadcastBuckets),
 		reqq:     make(chan *ChatRequest, maxChatRoomReq),
 		done:     d,
 		log:      cl,
 		wg:       g,
 	}
+	if h != nil { // Continue the message sequence from any previous log.
+		r.lastMsg = h.lastMessageID()
+	}
+	return r
 }
 
 // Run is the main routine that is evoked in background to accept commands to the room.
 func (r *ChatRoom) Run() {
 	defer r.wg.Done()
+	r.mu.Lock()
 	r.start = time.Now()
+	r.mu.Unlock()
 	for {
 		select {
 		case <-r.done: // Server signal quit
@@ -69,6 +132,42 @@ func (r *ChatRoom) Run() {
 				r.message(req)
 			case ChatReqTypeLeave:
 				r.leave(req)
+			case ChatReqTypeHistoryPage:
+				r.historyPage(req)
+			case ChatReqTypePrivateMsg:
+				r.privateMessage(req)
+			case ChatReqTypeKick:
+				r.kick(req)
+			case ChatReqTypeBan:
+				r.ban(req)
+			case ChatReqTypeUnban:
+				r.unban(req)
+			case ChatReqTypeMute:
+				r.mute(req, true)
+			case ChatReqTypeUnmute:
+				r.mute(req, false)
+			case ChatReqTypeGrantOp:
+				r.operator(req, true)
+			case ChatReqTypeRevokeOp:
+				r.operator(req, false)
+			case ChatReqTypeSetPassword:
+				r.setPassword(req)
+			case ChatReqTypeSetInviteOnly:
+				r.setInviteOnly(req)
+			case ChatReqTypeInvite:
+				r.invite(req)
+			case ChatReqTypeEmote:
+				r.emote(req)
+			case ChatReqTypeTopic:
+				r.topic(req)
+			case ChatReqTypeTyping:
+				r.typing(req)
+			case ChatReqTypeSetReceipts:
+				r.setReceipts(req)
+			case ChatReqTypeAck:
+				r.ack(req)
+			case ChatReqTypeReceipts:
+				r.listReceipts(req)
 			default:
 				r.sendResponse(req.Who, ChatRspTypeErrUnknownReq,
 					fmt.Sprintf(`Unknown request sent to room "%s".`, r.Name()), nil)
@@ -87,6 +186,15 @@ func (r *ChatRoom) join(q *ChatRequest) {
 	case r.isMemberName(q.Who.Nickname()):
 		r.sendResponse(q.Who, ChatRspTypeErrNicknameUsed,
 			fmt.Sprintf(`Nickname "%s" is already in use in room "%s".`, q.Who.Nickname(), r.Name()), nil)
+	case r.isBanned(q.Who):
+		r.sendResponse(q.Who, ChatRspTypeErrBanned,
+			fmt.Sprintf(`You are banned from room "%s".`, r.Name()), nil)
+	case !r.isPrivileged(q.Who) && !r.isInvited(q.Who):
+		r.sendResponse(q.Who, ChatRspTypeErrNotInvited,
+			fmt.Sprintf(`You have not been invited to room "%s".`, r.Name()), nil)
+	case !r.isPrivileged(q.Who) && !r.isPassword(q.Password):
+		r.sendResponse(q.Who, ChatRspTypeErrWrongPassword,
+			fmt.Sprintf(`Wrong password for room "%s".`, r.Name()), nil)
 	default:
 		r.mu.Lock()
 		r.chatters[q.Who] = false
@@ -96,29 +204,61 @@ func (r *ChatRoom) join(q *ChatRequest) {
 		var names []string
 		for c, hidden := range r.chatters {
 			if !hidden { // don't return hidden names.
-				names = append(names, c.Nickname())
+				names = append(names, c.listName())
 			}
 		}
 		r.mu.Unlock()
+		r.replayHistory(q.Who)
 		r.sendResponseAll(ChatRspTypeJoin, fmt.Sprintf("%s has joined the room.", q.Who.Nickname()), names)
 	}
 }
 
-// listNames sends a response to the user with a list of all nicknames in the room.
+// replayHistory sends the most recent messages of the room to a chatter.
+func (r *ChatRoom) replayHistory(c *Chatter) {
+	if r.history == nil {
+		return
+	}
+	msgs := r.history.last()
+	if len(msgs) == 0 {
+		return
+	}
+	r.sendMessages(c, ChatRspTypeHistory, "", msgs)
+}
+
+// listNames sends a response to the user with a list of all nicknames in the room and the
+// presence of each.
 func (r *ChatRoom) listNames(q *ChatRequest) {
-	var names []string
+	names := []string{}
+	presence := make(map[string]string)
 	r.mu.RLock()
 	for c, hidden := range r.chatters {
 		if !hidden { // don't return hidden names.
-			names = append(names, c.Nickname())
+			names = append(names, c.listName())
+			presence[c.Nickname()] = c.Presence()
 		}
 	}
 	r.mu.RUnlock()
-	r.sendResponse(q.Who, ChatRspTypeListNames, "", names)
+	rsp, err := ChatResponseNew(r.Name(), ChatRspTypeListNames, "", names)
+	if err != nil {
+		return
+	}
+	rsp.Presence = presence
+	q.Who.queueResponse(rsp)
+	r.mu.Lock()
+	r.lastRsp = time.Now()
+	r.rspCount++
+	r.mu.Unlock()
 }
 
 // hide visually makes a nickname inactive in the user list
 func (r *ChatRoom) hide(q *ChatRequest) {
+	if !r.isMember(q.Who) {
+		r.sendNotMemberError(q.Who)
+		return
+	}
+	if r.stopTyping(q.Who) {
+		r.sendTyping(q.Who, false)
+	}
 	r.mu.Lock()
 	r.chatters[q.Who] = true
 	r.mu.Unlock()
@@ -127,6 +267,10 @@ func (r *ChatRoom) hide(q *ChatRequest) {
 
 // unhide visually makes a nickname active in the user list
 func (r *ChatRoom) unhide(q *ChatRequest) {
+	if !r.isMember(q.Who) {
+		r.sendNotMemberError(q.Who)
+		return
+	}
 	r.mu.Lock()
 	r.chatters[q.Who] = false
 	r.mu.Unlock()
@@ -135,18 +279,208 @@ func (r *ChatRoom) unhide(q *ChatRequest) {
 
 // message sends a message from a chatter to everyone in the room.
 func (r *ChatRoom) message(q *ChatRequest) {
+	if !r.canPost(q.Who) {
+		return
+	}
+	r.stopTyping(q.Who) // The message itself ends the typing notice.
+	r.mu.Lock()
+	r.lastMsg++
+	r.msgsIn++
+	m := ChatMessageNew(r.lastMsg, q.Who.Nickname(), q.Content)
+	r.mu.Unlock()
+	if r.history != nil {
+		if err := r.history.append(m); err != nil {
+			r.log.Errorf("Cannot write history for room \"%s\": %s", r.Name(), err.Error())
+		}
+	}
+	r.sendMessageAll(m)
+}
+
+// emote sends an action of a chatter to everyone in the room, ex: "* ChatMonkey waves".
+func (r *ChatRoom) emote(q *ChatRequest) {
+	if !r.canPost(q.Who) {
+		return
+	}
+	r.stopTyping(q.Who)
+	r.sendResponseAll(ChatRspTypeEmote, fmt.Sprintf("* %s %s", q.Who.Nickname(), q.Content), nil)
+}
+
+// typing tells the other members of the room whether the chatter is typing. Content is "start" or
+// "stop". The notice is not kept or counted as a message, and stops by itself if no stop arrives
+// in time. Hidden chatters are never shown typing.
+func (r *ChatRoom) typing(q *ChatRequest) {
+	ttl, _ := q.Who.cMngr.typingLimits()
+	start := q.Content != "stop"
+	r.mu.Lock()
+	hidden, ok := r.chatters[q.Who]
+	if !ok || hidden {
+		r.mu.Unlock()
+		if !ok {
+			r.sendResponse(q.Who, ChatRspTypeErrNotMember,
+				fmt.Sprintf(`You are not a member of room "%s".`, r.name), nil)
+		}
+		return
+	}
+	_, was := r.typers[q.Who]
+	delete(r.typers, q.Who)
+	if start {
+		var until time.Time
+		if ttl > 0 {
+			until = time.Now().Add(ttl)
+			time.AfterFunc(ttl, func() { r.expireTyping(q.Who, until) })
+		}
+		r.typers[q.Who] = until
+	}
+	r.mu.Unlock()
+	if start != was { // Only changes are sent, so a client may repeat a start to keep it alive.
+		r.sendTyping(q.Who, start)
+	}
+}
+
+// expireTyping stops the typing notice of a chatter if it has not been renewed or stopped since.
+func (r *ChatRoom) expireTyping(c *Chatter, until time.Time) {
+	r.mu.Lock()
+	t, ok := r.typers[c]
+	expired := ok && t.Equal(until)
+	if expired {
+		delete(r.typers, c)
+	}
+	r.mu.Unlock()
+	if expired {
+		r.sendTyping(c, false)
+	}
+}
+
+// stopTyping forgets any typing notice of a chatter without telling the room, and returns whether
+// there was one.
+func (r *ChatRoom) stopTyping(c *Chatter) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.typers[c]
+	delete(r.typers, c)
+	return ok
+}
+
+// sendTyping tells the members of the room other than the chatter that it started or stopped
+// typing.
+func (r *ChatRoom) sendTyping(c *Chatter, start bool) {
+	cont := "stop"
+	if start {
+		cont = "start"
+	}
+	l := []string{c.Nickname()}
+	r.mu.Lock()
+	for o := range r.chatters {
+		if o != c {
+			o.sendResponse(r.name, ChatRspTypeTyping, cont, l)
+			r.lastRsp = time.Now()
+			r.rspCount++
+		}
+	}
+	r.mu.Unlock()
+}
+
+// canPost validates whether the chatter may post in the room, telling the chatter if not.
+func (r *ChatRoom) canPost(c *Chatter) bool {
+	r.mu.RLock()
+	isHidden := r.chatters[c]
+	r.mu.RUnlock()
+	switch {
+	case isHidden:
+		r.sendResponse(c, ChatRspTypeErrHiddenNickname,
+			fmt.Sprintf(`Nickname "%s" is hidden. Cannot post in room "%s".`, c.Nickname(), r.Name()), nil)
+		return false
+	case r.isMuted(c):
+		r.sendMutedError(c)
+		return false
+	}
+	return true
+}
+
+// topic sends the topic of the room to the chatter or, with content, sets it. Operators and
+// better may set the topic.
+func (r *ChatRoom) topic(q *ChatRequest) {
+	if q.Content == "" {
+		r.mu.RLock()
+		t := r.subject
+		r.mu.RUnlock()
+		if t == "" {
+			r.sendResponse(q.Who, ChatRspTypeTopic, fmt.Sprintf(`No topic is set for room "%s".`, r.Name()), nil)
+			return
+		}
+		r.sendResponse(q.Who, ChatRspTypeTopic, fmt.Sprintf(`The topic of room "%s" is: %s`, r.Name(), t), nil)
+		return
+	}
+	if !r.authorizeRole(q, chatRoomRoleOperator) {
+		return
+	}
+	r.mu.Lock()
+	r.subject = q.Content
+	r.mu.Unlock()
+	r.notifyAll(q.Who, ChatRspTypeTopic, fmt.Sprintf("%s set the topic to: %s", q.Who.Nickname(), q.Content))
+}
+
+// privateMessage sends a message from a chatter to one other visible chatter in the room.
+func (r *ChatRoom) privateMessage(q *ChatRequest) {
+	if q.Target == "" {
+		r.sendResponse(q.Who, ChatRspTypeErrNicknameMandatory, "target nickname is mandatory", nil)
+		return
+	}
+	var to *Chatter
 	r.mu.RLock()
-	isHidden := r.chatters[q.Who]
+	isHidden, isMember := r.chatters[q.Who]
+	for c, hidden := range r.chatters {
+		if !hidden && c.Nickname() == q.Target {
+			to = c
+			break
+		}
+	}
 	r.mu.RUnlock()
-	if isHidden {
+	switch {
+	case !isMember:
+		r.sendResponse(q.Who, ChatRspTypeErrNotMember,
+			fmt.Sprintf(`You are not a member of room "%s".`, r.Name()), nil)
+	case isHidden:
 		r.sendResponse(q.Who, ChatRspTypeErrHiddenNickname,
 			fmt.Sprintf(`Nickname "%s" is hidden. Cannot post in room "%s".`, q.Who.Nickname(),
-				r.name), nil)
-	} else {
-		r.sendResponseAll(ChatRspTypeMsg, fmt.Sprintf("%s: %s", q.Who.Nickname(), q.Content), nil)
+				r.Name()), nil)
+	case r.isMuted(q.Who):
+		r.sendMutedError(q.Who)
+	case to == nil:
+		r.sendResponse(q.Who, ChatRspTypeErrNicknameUnknown,
+			fmt.Sprintf(`Nickname "%s" is not in room "%s".`, q.Target, r.Name()), nil)
+	default:
+		cont := fmt.Sprintf("%s: %s", q.Who.Nickname(), q.Content)
+		l := []string{q.Who.Nickname(), to.Nickname()}
+		r.sendResponse(q.Who, ChatRspTypePrivateMsg, cont, l)
+		if to != q.Who {
+			r.sendResponse(to, ChatRspTypePrivateMsg, cont, l)
+		}
 	}
 }
 
+// historyPage sends the chatter a page of messages older than the cursor in the request and
+// the cursor to request the next older page with.
+func (r *ChatRoom) historyPage(q *ChatRequest) {
+	if !r.isMember(q.Who) {
+		r.sendNotMemberError(q.Who)
+		return
+	}
+	if r.history == nil {
+		r.sendResponse(q.Who, ChatRspTypeErrHistoryDisabled,
+			fmt.Sprintf(`History is not enabled for room "%s".`, r.Name()), nil)
+		return
+	}
+	msgs, next, err := r.history.page(q.Before, q.Limit)
+	if err != nil {
+		r.log.Errorf("Cannot read history for room \"%s\": %s", r.Name(), err.Error())
+		r.sendResponse(q.Who, ChatRspTypeErrHistoryDisabled,
+			fmt.Sprintf(`History is unavailable for room "%s".`, r.Name()), nil)
+		return
+	}
+	r.sendMessages(q.Who, ChatRspTypeHistoryPage, strconv.FormatUint(next, 10), msgs)
+}
+
 // leave removes the chatter from the room and notifies the group the chatter has left.
 func (r *ChatRoom) leave(q *ChatRequest) {
 	if ok := r.isMember(q.Who); !ok {
@@ -156,9 +490,11 @@ func (r *ChatRoom) leave(q *ChatRequest) {
 	var names []string
 	r.mu.Lock()
 	delete(r.chatters, q.Who)
+	delete(r.typers, q.Who)
+	delete(r.reads, q.Who)
 	for c, hidden := range r.chatters {
 		if !hidden { // don't return hidden names.
-			names = append(names, c.Nickname())
+			names = append(names, c.listName())
 		}
 	}
 	r.mu.Unlock()
@@ -166,9 +502,515 @@ func (r *ChatRoom) leave(q *ChatRequest) {
 	r.sendResponseAll(ChatRspTypeLeave, fmt.Sprintf("%s has left the room.", name), names)
 }
 
+// kick removes a chatter from the room.
+func (r *ChatRoom) kick(q *ChatRequest) {
+	if !r.authorizeModeration(q, chatRoomRoleOperator) {
+		return
+	}
+	target := r.memberByName(q.Target)
+	if target == nil {
+		r.sendResponse(q.Who, ChatRspTypeErrNicknameUnknown,
+			fmt.Sprintf(`Nickname "%s" is not in room "%s".`, q.Target, r.Name()), nil)
+		return
+	}
+	r.expel(target, ChatRspTypeKick, fmt.Sprintf(`You have been kicked from room "%s" by %s.`,
+		r.Name(), q.Who.Nickname()))
+	r.notifyAll(q.Who, ChatRspTypeKick, fmt.Sprintf("%s was kicked by %s.", q.Target, q.Who.Nickname()))
+}
+
+// ban prevents a nickname or IP from joining the room. A banned chatter in the room is removed
+// and their IP is banned along with the nickname.
+func (r *ChatRoom) ban(q *ChatRequest) {
+	if !r.authorizeModeration(q, chatRoomRoleOperator) {
+		return
+	}
+	target := r.memberByName(q.Target)
+	ip := ""
+	if target != nil {
+		ip = target.remoteIP()
+	}
+	r.mu.Lock()
+	r.bans[q.Target] = ip
+	if ip != "" {
+		r.bans[ip] = ip
+	}
+	r.mu.Unlock()
+	if target != nil {
+		r.expel(target, ChatRspTypeBan, fmt.Sprintf(`You have been banned from room "%s" by %s.`,
+			r.Name(), q.Who.Nickname()))
+	}
+	r.notifyAll(q.Who, ChatRspTypeBan, fmt.Sprintf("%s was banned by %s.", q.Target, q.Who.Nickname()))
+}
+
+// unban allows a nickname or IP to join the room again.
+func (r *ChatRoom) unban(q *ChatRequest) {
+	if !r.authorizeModeration(q, chatRoomRoleOperator) {
+		return
+	}
+	r.mu.Lock()
+	ip, ok := r.bans[q.Target]
+	delete(r.bans, q.Target)
+	if ip != "" {
+		delete(r.bans, ip)
+	}
+	r.mu.Unlock()
+	if !ok {
+		r.sendResponse(q.Who, ChatRspTypeErrNicknameUnknown,
+			fmt.Sprintf(`"%s" is not banned from room "%s".`, q.Target, r.Name()), nil)
+		return
+	}
+	r.notifyAll(q.Who, ChatRspTypeUnban, fmt.Sprintf("%s was unbanned by %s.", q.Target, q.Who.Nickname()))
+}
+
+// mute sets whether a nickname is allowed to post in the room.
+func (r *ChatRoom) mute(q *ChatRequest, muted bool) {
+	if !r.authorizeModeration(q, chatRoomRoleOperator) {
+		return
+	}
+	// The connection is muted along with the identity so dropping the nickname does not unmute it.
+	keys := []chatRoomKey{r.targetKey(q)}
+	if to := r.targetChatter(q); to != nil {
+		keys = append(keys, chatRoomKey{conn: to})
+	}
+	r.mu.Lock()
+	for _, k := range keys {
+		if muted {
+			r.muted[k] = true
+		} else {
+			delete(r.muted, k)
+		}
+	}
+	r.mu.Unlock()
+	if muted {
+		r.notifyAll(q.Who, ChatRspTypeMute, fmt.Sprintf("%s was muted by %s.", q.Target, q.Who.Nickname()))
+	} else {
+		r.notifyAll(q.Who, ChatRspTypeUnmute, fmt.Sprintf("%s was unmuted by %s.", q.Target, q.Who.Nickname()))
+	}
+}
+
+// operator grants or revokes the operator role of a nickname in the room.
+func (r *ChatRoom) operator(q *ChatRequest, grant bool) {
+	if !r.authorizeModeration(q, chatRoomRoleOwner) {
+		return
+	}
+	k := r.targetKey(q)
+	r.mu.Lock()
+	if grant {
+		r.ops[k] = true
+	} else {
+		delete(r.ops, k)
+	}
+	r.mu.Unlock()
+	if grant {
+		r.notifyAll(q.Who, ChatRspTypeGrantOp,
+			fmt.Sprintf("%s is now an operator, granted by %s.", q.Target, q.Who.Nickname()))
+	} else {
+		r.notifyAll(q.Who, ChatRspTypeRevokeOp,
+			fmt.Sprintf("%s is no longer an operator, revoked by %s.", q.Target, q.Who.Nickname()))
+	}
+}
+
+// setPassword sets or, if blank, removes the password needed to join the room.
+func (r *ChatRoom) setPassword(q *ChatRequest) {
+	if !r.authorizeRole(q, chatRoomRoleOwner) {
+		return
+	}
+	r.mu.Lock()
+	r.password = nil
+	if q.Password != "" {
+		r.password = chatRoomHash(q.Password)
+	}
+	r.mu.Unlock()
+	if q.Password != "" {
+		r.notifyAll(q.Who, ChatRspTypeSetPassword, "The room now requires a password to join.")
+	} else {
+		r.notifyAll(q.Who, ChatRspTypeSetPassword, "The room no longer requires a password to join.")
+	}
+}
+
+// setInviteOnly sets whether joining the room requires an invitation. Content is "on" or "off".
+func (r *ChatRoom) setInviteOnly(q *ChatRequest) {
+	if !r.authorizeRole(q, chatRoomRoleOwner) {
+		return
+	}
+	on := q.Content == "on"
+	r.mu.Lock()
+	r.inviteOn = on
+	r.mu.Unlock()
+	if on {
+		r.notifyAll(q.Who, ChatRspTypeSetInviteOnly, "The room is now invite only.")
+	} else {
+		r.notifyAll(q.Who, ChatRspTypeSetInviteOnly, "The room is no longer invite only.")
+	}
+}
+
+// setReceipts sets whether read receipts are kept for the room. Content is "on" or "off". Turning
+// them off forgets every read position.
+func (r *ChatRoom) setReceipts(q *ChatRequest) {
+	if !r.authorizeRole(q, chatRoomRoleOwner) {
+		return
+	}
+	on := q.Content == "on"
+	r.mu.Lock()
+	r.receipts = on
+	if !on {
+		r.reads = make(map[*Chatter]uint64)
+	}
+	r.mu.Unlock()
+	if on {
+		r.notifyAll(q.Who, ChatRspTypeSetReceipts, "Read receipts are now on for the room.")
+	} else {
+		r.notifyAll(q.Who, ChatRspTypeSetReceipts, "Read receipts are now off for the room.")
+	}
+}
+
+// ack moves the read position of a member to the message displayed and tells the room. Positions
+// only move forward, and hidden members are not shown to the room.
+func (r *ChatRoom) ack(q *ChatRequest) {
+	r.mu.Lock()
+	on, last := r.receipts, r.lastMsg
+	hidden, ok := r.chatters[q.Who]
+	moved := on && ok && q.ReadID > 0 && q.ReadID <= last && q.ReadID > r.reads[q.Who]
+	if moved {
+		r.reads[q.Who] = q.ReadID
+	}
+	r.mu.Unlock()
+	switch {
+	case !on:
+		r.sendResponse(q.Who, ChatRspTypeErrReceiptsDisabled,
+			fmt.Sprintf(`Read receipts are not enabled for room "%s".`, r.Name()), nil)
+	case !ok:
+		r.sendResponse(q.Who, ChatRspTypeErrNotMember,
+			fmt.Sprintf(`You are not a member of room "%s".`, r.Name()), nil)
+	case q.ReadID == 0 || q.ReadID > last:
+		r.sendResponse(q.Who, ChatRspTypeErrMessageUnknown,
+			fmt.Sprintf(`Message %d is not in room "%s".`, q.ReadID, r.Name()), nil)
+	case moved && !hidden:
+		name := q.Who.Nickname()
+		rsp, err := ChatResponseNew(r.Name(), ChatRspTypeReceipt,
+			fmt.Sprintf("%s has read up to message %d.", name, q.ReadID), []string{})
+		if err != nil {
+			return
+		}
+		rsp.Receipts = map[string]uint64{name: q.ReadID}
+		r.mu.Lock()
+		for c := range r.chatters {
+			c.queueResponse(rsp)
+			r.lastRsp = time.Now()
+			r.rspCount++
+		}
+		r.mu.Unlock()
+	}
+}
+
+// listReceipts sends the chatter the read position of each visible member of the room.
+func (r *ChatRoom) listReceipts(q *ChatRequest) {
+	r.mu.RLock()
+	on := r.receipts
+	reads := make(map[string]uint64)
+	for c, id := range r.reads {
+		if hidden, ok := r.chatters[c]; ok && !hidden {
+			reads[c.Nickname()] = id
+		}
+	}
+	r.mu.RUnlock()
+	if !on {
+		r.sendResponse(q.Who, ChatRspTypeErrReceiptsDisabled,
+			fmt.Sprintf(`Read receipts are not enabled for room "%s".`, r.Name()), nil)
+		return
+	}
+	rsp, err := ChatResponseNew(r.Name(), ChatRspTypeReceipts, "", []string{})
+	if err != nil {
+		return
+	}
+	rsp.Receipts = reads
+	q.Who.queueResponse(rsp)
+	r.mu.Lock()
+	r.lastRsp = time.Now()
+	r.rspCount++
+	r.mu.Unlock()
+}
+
+// invite allows a nickname to join the room when it is invite only, and tells the chatter using
+// the nickname if they are on the server.
+func (r *ChatRoom) invite(q *ChatRequest) {
+	if !r.authorizeModeration(q, chatRoomRoleOperator) {
+		return
+	}
+	k := r.targetKey(q)
+	r.mu.Lock()
+	r.invited[k] = true
+	r.mu.Unlock()
+	r.sendResponse(q.Who, ChatRspTypeInvite, fmt.Sprintf(`%s is invited to room "%s".`, q.Target, r.Name()), nil)
+	if to, err := q.Who.cMngr.findChatter(q.Target); err == nil && to != q.Who {
+		r.sendResponse(to, ChatRspTypeInvite,
+			fmt.Sprintf(`You have been invited to room "%s" by %s.`, r.Name(), q.Who.Nickname()), nil)
+	}
+}
+
+// authorizeModeration validates the chatter is a member with at least the role required for the
+// request and outranks the target of the request.
+func (r *ChatRoom) authorizeModeration(q *ChatRequest, role int) bool {
+	if !r.isMember(q.Who) {
+		r.sendNotMemberError(q.Who)
+		return false
+	}
+	if q.Target == "" {
+		r.sendResponse(q.Who, ChatRspTypeErrNicknameMandatory, "target nickname is mandatory", nil)
+		return false
+	}
+	actor := r.actorRole(q.Who)
+	if actor < role || r.role(r.targetKey(q)) >= actor {
+		r.sendResponse(q.Who, ChatRspTypeErrNotAuthorized,
+			fmt.Sprintf(`You are not authorized to moderate "%s" in room "%s".`, q.Target, r.Name()), nil)
+		return false
+	}
+	return true
+}
+
+// authorizeRole validates the chatter is a member with at least the role required for the request.
+func (r *ChatRoom) authorizeRole(q *ChatRequest, role int) bool {
+	if !r.isMember(q.Who) {
+		r.sendNotMemberError(q.Who)
+		return false
+	}
+	if r.actorRole(q.Who) < role {
+		r.sendResponse(q.Who, ChatRspTypeErrNotAuthorized,
+			fmt.Sprintf(`You are not authorized to change room "%s".`, r.Name()), nil)
+		return false
+	}
+	return true
+}
+
+// actorRole returns the role of a chatter in the room. Admins act as owners of every room.
+func (r *ChatRoom) actorRole(c *Chatter) int {
+	if c.isAdmin() {
+		return chatRoomRoleOwner
+	}
+	return r.role(chatRoomKeyOf(c))
+}
+
+// targetChatter returns the chatter using the target nickname of a request, looking in the room
+// first and then on the server, or nil if there is none.
+func (r *ChatRoom) targetChatter(q *ChatRequest) *Chatter {
+	if c := r.memberByName(q.Target); c != nil {
+		return c
+	}
+	if c, err := q.Who.cMngr.findChatter(q.Target); err == nil {
+		return c
+	}
+	return nil
+}
+
+// targetKey returns the key of the target of a request. A nickname not in use is taken as the
+// identity it must be proven for.
+func (r *ChatRoom) targetKey(q *ChatRequest) chatRoomKey {
+	if c := r.targetChatter(q); c != nil {
+		return chatRoomKeyOf(c)
+	}
+	return chatRoomKey{ident: q.Target}
+}
+
+// isPrivileged validates if the chatter is an operator or better and can bypass room modes.
+func (r *ChatRoom) isPrivileged(c *Chatter) bool {
+	return r.actorRole(c) >= chatRoomRoleOperator
+}
+
+// isInvited validates if the chatter may join when the room is invite only.
+func (r *ChatRoom) isInvited(c *Chatter) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !r.inviteOn || r.invited[chatRoomKeyOf(c)]
+}
+
+// isPassword validates the password supplied to join the room.
+func (r *ChatRoom) isPassword(pw string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.password == nil || subtle.ConstantTimeCompare(r.password, chatRoomHash(pw)) == 1
+}
+
+// chatRoomHash returns the hash a room password is kept as.
+func chatRoomHash(pw string) []byte {
+	h := sha256.Sum256([]byte(pw))
+	return h[:]
+}
+
+// role returns the role held by a key in the room.
+func (r *ChatRoom) role(k chatRoomKey) int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	switch {
+	case k != (chatRoomKey{}) && k == r.owner:
+		return chatRoomRoleOwner
+	case r.ops[k]:
+		return chatRoomRoleOperator
+	default:
+		return chatRoomRoleMember
+	}
+}
+
+// renameMember tells the room a member has a new nickname. Roles stay with the identity or the
+// chatter that holds them. The change is not announced for a hidden member.
+func (r *ChatRoom) renameMember(c *Chatter, old string, name string) {
+	r.mu.RLock()
+	hidden := r.chatters[c]
+	r.mu.RUnlock()
+	if hidden {
+		return
+	}
+	r.sendResponseAll(ChatRspTypeNicknameChanged, fmt.Sprintf("%s is now known as %s.", old, name),
+		r.visibleNames())
+}
+
+// proveMember moves the owner and operator roles a chatter holds by its connection to the identity
+// it has proven.
+func (r *ChatRoom) proveMember(c *Chatter) {
+	from, to := chatRoomKey{conn: c}, chatRoomKeyOf(c)
+	if from == to {
+		return
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.owner == from {
+		r.owner = to
+	}
+	if r.ops[from] {
+		delete(r.ops, from)
+		r.ops[to] = true
+	}
+}
+
+// presenceChanged tells the room of the presence of a member. The change is not announced for a
+// hidden member.
+func (r *ChatRoom) presenceChanged(c *Chatter) {
+	r.mu.RLock()
+	hidden, ok := r.chatters[c]
+	r.mu.RUnlock()
+	if !ok || hidden {
+		return
+	}
+	name, presence := c.Nickname(), c.Presence()
+	rsp, err := ChatResponseNew(r.Name(), ChatRspTypePresence, fmt.Sprintf("%s is now %s.", name, presence),
+		[]string{})
+	if err != nil {
+		return
+	}
+	rsp.Presence = map[string]string{name: presence}
+	r.mu.Lock()
+	for m := range r.chatters {
+		m.queueResponse(rsp)
+		r.lastRsp = time.Now()
+		r.rspCount++
+	}
+	r.mu.Unlock()
+}
+
+// adminKick removes a chatter from the room at the request of the server administrator.
+func (r *ChatRoom) adminKick(name string) error {
+	target := r.memberByName(name)
+	if target == nil {
+		return chatManagerErrNicknameNotFound
+	}
+	r.expel(target, ChatRspTypeKick,
+		fmt.Sprintf(`You have been kicked from room "%s" by the server administrator.`, r.Name()))
+	r.sendResponseAll(ChatRspTypeKick, fmt.Sprintf("%s was kicked by the server administrator.", name),
+		r.visibleNames())
+	return nil
+}
+
+// announce sends a system announcement to all chatters in the room.
+func (r *ChatRoom) announce(cont string) {
+	r.sendResponseAll(ChatRspTypeAnnouncement, cont, nil)
+}
+
+// expel removes a chatter from the room and tells the chatter why.
+func (r *ChatRoom) expel(c *Chatter, rspt int, cont string) {
+	r.mu.Lock()
+	delete(r.chatters, c)
+	delete(r.typers, c)
+	delete(r.reads, c)
+	r.mu.Unlock()
+	r.sendResponse(c, rspt, cont, nil)
+}
+
+// notifyAll sends a notice with the visible nicknames to all chatters in the room and to the
+// chatter who caused it if they are not in the room.
+func (r *ChatRoom) notifyAll(from *Chatter, rspt int, cont string) {
+	if !r.isMember(from) {
+		r.sendResponse(from, rspt, cont, r.visibleNames())
+	}
+	r.sendResponseAll(rspt, cont, r.visibleNames())
+}
+
+// visibleNames returns the nicknames in the room that are not hidden.
+func (r *ChatRoom) visibleNames() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	var names []string
+	for c, hidden := range r.chatters {
+		if !hidden { // don't return hidden names.
+			names = append(names, c.listName())
+		}
+	}
+	return names
+}
+
+// memberByName returns the chatter in the room using a nickname.
+func (r *ChatRoom) memberByName(name string) *Chatter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for c := range r.chatters {
+		if c.Nickname() == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// isBanned validates if the nickname or IP of a chatter is banned from the room.
+func (r *ChatRoom) isBanned(c *Chatter) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.bans[c.Nickname()]; ok {
+		return true
+	}
+	if id := c.identity(); id != "" {
+		if _, ok := r.bans[id]; ok {
+			return true
+		}
+	}
+	ip := c.remoteIP()
+	_, ok := r.bans[ip]
+	return ip != "" && ok
+}
+
+// isMuted validates if a chatter, by its identity or its connection, is muted in the room.
+func (r *ChatRoom) isMuted(c *Chatter) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.muted[chatRoomKeyOf(c)] || r.muted[chatRoomKey{conn: c}]
+}
+
+// sendNotMemberError tells a chatter they are not a member of the room.
+func (r *ChatRoom) sendNotMemberError(c *Chatter) {
+	r.sendResponse(c, ChatRspTypeErrNotMember, fmt.Sprintf(`You are not a member of room "%s".`, r.Name()), nil)
+}
+
+// sendMutedError tells a chatter they cannot post because they are muted.
+func (r *ChatRoom) sendMutedError(c *Chatter) {
+	r.sendResponse(c, ChatRspTypeErrMuted,
+		fmt.Sprintf(`Nickname "%s" is muted. Cannot post in room "%s".`, c.Nickname(), r.Name()), nil)
+}
+
 // ChatRoomStats is a simple structure for returning statistic information on the room.
 type ChatRoomStats struct {
 	Name     string                 `json:"name"`     // The name of the room.
+	Owner    string                 `json:"owner"`    // The nickname of the owner of the room.
+	Locked   bool                   `json:"locked"`   // Is a password needed to join the room?
+	Invite   bool                   `json:"invite"`   // Is joining the room by invitation only?
+	Topic    string                 `json:"topic"`    // The topic of the room.
+	Receipts bool                   `json:"receipts"` // Are read receipts kept for the room?
 	Start    time.Time              `json:"start"`    // The start time of the room.
 	LastReq  time.Time              `json:"lastReq"`  // The last request time to the room.
 	LastRsp  time.Time              `json:"lastRsp"`  // The last response time from the room.
@@ -180,6 +1022,7 @@ type ChatRoomStats struct {
 type ChatRoomChatterStat struct {
 	Nickname   string `json:"nickname"`   // The nickname of the chatter.
 	RemoteAddr string `json:"remoteAddr"` // The remote IP and port of the chatter.
+	Bot        bool   `json:"bot"`        // Is the chatter a bot running inside the server?
 }
 
 // ChatRoomStatsNew returns status information on the room.
@@ -188,6 +1031,11 @@ func (r *ChatRoom) ChatRoomStatsNew() *ChatRoomStats {
 	defer r.mu.RUnlock()
 	stat := &ChatRoomStats{
 		Name:     r.name,
+		Owner:    r.owner.name(),
+		Locked:   r.password != nil,
+		Invite:   r.inviteOn,
+		Topic:    r.subject,
+		Receipts: r.receipts,
 		Start:    r.start,
 		LastReq:  r.lastReq,
 		LastRsp:  r.lastRsp,
@@ -200,19 +1048,43 @@ func (r *ChatRoom) ChatRoomStatsNew() *ChatRoomStats {
 		stat.Chatters = append(stat.Chatters, &ChatRoomChatterStat{
 			Nickname:   ctrStat.Nickname,
 			RemoteAddr: ctrStat.RemoteAddr,
+			Bot:        ctrStat.Bot,
 		})
 	}
 	return stat
 }
 
-// isEmpty validates whether the room is empty of chatters.
-func (r *ChatRoom) isEmpty() bool {
+// metrics returns a snapshot of the counters of the room for the metrics route.
+func (r *ChatRoom) metrics() *chatRoomMetrics {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
-	if len(r.chatters) > 0 {
-		return false
+	return &chatRoomMetrics{
+		name:      r.name,
+		members:   len(r.chatters),
+		reqCount:  r.reqCount,
+		rspCount:  r.rspCount,
+		msgsIn:    r.msgsIn,
+		msgsOut:   r.msgsOut,
+		queue:     len(r.reqq),
+		broadcast: r.bcast.snapshot(),
 	}
-	return true
+}
+
+// isOwner validates if the chatter owns the room.
+func (r *ChatRoom) isOwner(c *Chatter) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.owner == chatRoomKeyOf(c)
+}
+
+// isEmptyBut validates whether the room has no chatters other than the one given, if any.
+func (r *ChatRoom) isEmptyBut(c *Chatter) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if _, ok := r.chatters[c]; ok {
+		return len(r.chatters) == 1
+	}
+	return len(r.chatters) == 0
 }
 
 // isMember validates if the member exists in the room.
@@ -252,6 +1124,7 @@ func (r *ChatRoom) sendResponseAll(rspt int, cont string, l []string) {
 	if l == nil {
 		l = []string{}
 	}
+	start := time.Now()
 	r.mu.Lock()
 	for c := range r.chatters {
 		c.sendResponse(r.name, rspt, cont, l)
@@ -259,6 +1132,44 @@ func (r *ChatRoom) sendResponseAll(rspt int, cont string, l []string) {
 		r.rspCount++
 	}
 	r.mu.Unlock()
+	r.bcast.observeSince(start)
+}
+
+// sendMessages sends a list of messages to a single chatter in the room.
+func (r *ChatRoom) sendMessages(c *Chatter, rspt int, cont string, msgs []*ChatMessage) {
+	lines := []string{}
+	for _, m := range msgs {
+		lines = append(lines, m.formatted())
+	}
+	rsp, err := ChatResponseNew(r.Name(), rspt, cont, lines)
+	if err != nil {
+		return
+	}
+	rsp.Messages = msgs
+	c.queueResponse(rsp)
+	r.mu.Lock()
+	r.lastRsp = time.Now()
+	r.rspCount++
+	r.mu.Unlock()
+}
+
+// sendMessageAll sends a message posted to the room to all chatters in the room.
+func (r *ChatRoom) sendMessageAll(m *ChatMessage) {
+	rsp, err := ChatResponseNew(r.Name(), ChatRspTypeMsg, m.formatted(), []string{})
+	if err != nil {
+		return
+	}
+	rsp.Message = m
+	start := time.Now()
+	r.mu.Lock()
+	for c := range r.chatters {
+		c.queueResponse(rsp)
+		r.lastRsp = time.Now()
+		r.rspCount++
+		r.msgsOut++
+	}
+	r.mu.Unlock()
+	r.bcast.observeSince(start)
 }
 
 // Name returns the current name of the room.
@@ -274,3 +1185,18 @@ func (r *ChatRoom) SetName(name string) {
 	defer r.mu.Unlock()
 	r.name = name
 }
+
+// Owner returns the nickname of the owner of the room.
+func (r *ChatRoom) Owner() string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.owner.name()
+}
+
+// SetOwner sets the chatter who owns the room. The room is owned by the identity of the chatter
+// if it has proven one.
+func (r *ChatRoom) SetOwner(c *Chatter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.owner = chatRoomKeyOf(c)
+}
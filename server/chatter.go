@@ -1,32 +1,55 @@
 package server
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"runtime"
 	"strings"
 	"sync"
 	"time"
+	"unicode/utf8"
 
 	"golang.org/x/net/websocket"
 )
 
+const (
+	chatterPresenceOnline = "online" // The presence of a chatter who is connected and active.
+	chatterPresenceAway   = "away"   // The presence of a chatter who has stepped away.
+	chatterPresenceBusy   = "busy"   // The presence of a chatter who does not want to be disturbed.
+)
+
 var (
-	maxChatterRsp = 1000 // The max number of responses in the response channel.
+	maxChatterRsp         = 1000        // The max number of responses in the response channel.
+	chatterThrottleWindow = time.Minute // The window throttled requests are counted in.
+	chatterAwayPoll       = time.Minute // How often the away idle time is checked while it is off.
+	chatterPingPoll       = time.Minute // How often the ping interval is checked while pings are off.
 )
 
 // Chatter is a wrapper around a connection that represents one chat client on the server.
 type Chatter struct {
-	mu       sync.RWMutex // For locking access to chatter attributes.
-	nickname string       // The friendly nickname to display in a conversation.
-	start    time.Time    // The start time of the connection.
-	lastReq  time.Time    // The last request time of the connection.
-	lastRsp  time.Time    // The last response time to the connection.
-	reqCount uint64       // Total requests received.
-	rspCount uint64       // Total responses sent.
+	mu        sync.RWMutex // For locking access to chatter attributes.
+	nickname  string       // The friendly nickname to display in a conversation.
+	locked    bool         // Is the nickname locked to an authenticated identity?
+	ident     string       // The registered nickname the chatter has identified for.
+	start     time.Time    // The start time of the connection.
+	lastReq   time.Time    // The last request time of the connection.
+	active    time.Time    // The last request time that counts against the idle timeout.
+	lastRsp   time.Time    // The last response time to the connection.
+	reqCount  uint64       // Total requests received.
+	rspCount  uint64       // Total responses sent.
+	throttled uint64       // Total requests dropped by rate limits.
+	msgLimit  *rateLimiter // The rate limit of messages sent.
+	joinLimit *rateLimiter // The rate limit of rooms joined.
+	strikes   int          // Requests throttled since strikeAt.
+	strikeAt  time.Time    // The start of the current throttle window.
+	bot       Bot          // The bot run by the chatter in place of a remote client, if any.
+	presence  string       // Online, away, busy or custom text set by the chatter.
+	idleAway  bool         // Was the chatter shown as away for being idle?
 
 	cMngr *ChatManager       // The chat manager this chatter is attached to.
 	ws    *websocket.Conn    // The socket to the remote client.
+	conn  *chatConn          // The connection under the socket, tracked for pings.
 	rspq  chan *ChatResponse // A channel to receive information to send to the remote client.
 	done  chan bool          // Signal that chatter is closed.
 	log   *ChatLogger        // Server logger
@@ -37,20 +60,24 @@ type Chatter struct {
 // ChatterNew is a factory function that returns a new Chatter instance
 func ChatterNew(cm *ChatManager, w *websocket.Conn, l *ChatLogger) *Chatter {
 	return &Chatter{
-		cMngr: cm,
-		ws:    w,
-		done:  make(chan bool, 1),
-		rspq:  make(chan *ChatResponse, maxChatterRsp),
-		log:   l,
+		presence: chatterPresenceOnline,
+		cMngr:    cm,
+		ws:       w,
+		conn:     chatConnFrom(w),
+		done:     make(chan bool, 1),
+		rspq:     make(chan *ChatResponse, maxChatterRsp),
+		log:      l,
 	}
 }
 
 // Run starts the event loop that manages the sending and receiving of information to the client.
 func (c *Chatter) Run() {
 	c.start = time.Now()
+	c.active = c.start
 	c.cMngr.wg.Add(1) // We let the big boss also perform waits for chatters, so it can close down,
-	c.wg.Add(1)       //   but we also have our own in send().
+	c.wg.Add(2)       //   but we also have our own in send() and watchAway().
 	go c.send()       // Spawn response handling to the client in the background.
+	go c.watchAway()  // Show the chatter as away when idle.
 	c.receive()       // Then wait on incoming requests.
 }
 
@@ -62,10 +89,30 @@ func (c *Chatter) receive() {
 		// Set optional idle timeout on the receive.
 		maxi := c.cMngr.MaxIdle()
 		if maxi > 0 {
-			c.ws.SetReadDeadline(time.Now().Add(time.Duration(maxi) * time.Second))
+			c.mu.RLock()
+			active := c.active
+			c.mu.RUnlock()
+			c.ws.SetReadDeadline(active.Add(time.Duration(maxi) * time.Second))
+		}
+		// Oversized frames are discarded as they are read rather than buffered.
+		lim := c.cMngr.sizeLimits()
+		c.ws.MaxPayloadBytes = lim.frame
+		var raw []byte
+		err := websocket.Message.Receive(c.ws, &raw)
+		if err == websocket.ErrFrameTooLarge {
+			c.sendResponse("", ChatRspTypeErrFrameTooLarge,
+				fmt.Sprintf("Request is larger than %d bytes.", lim.frame), nil)
+			continue
 		}
 		var req ChatRequest
-		if err := websocket.JSON.Receive(c.ws, &req); err != nil {
+		if err == nil && !utf8.Valid(raw) {
+			c.sendResponse("", ChatRspTypeErrInvalidUTF8, "Request is not valid UTF-8.", nil)
+			continue
+		}
+		if err == nil {
+			err = json.Unmarshal(raw, &req)
+		}
+		if err != nil {
 			e, ok := err.(net.Error)
 			switch {
 			case ok && e.Timeout():
@@ -81,11 +128,58 @@ func (c *Chatter) receive() {
 			}
 			return
 		}
+		_, typingIdle := c.cMngr.typingLimits()
 		c.mu.Lock()
 		c.lastReq = time.Now()
 		c.reqCount++
+		back := false
+		if req.ReqType != ChatReqTypeTyping || typingIdle { // Typing alone does not keep the chatter.
+			c.active = c.lastReq
+			if back = c.idleAway && req.ReqType != ChatReqTypeSetPresence; back {
+				c.presence = chatterPresenceOnline
+				c.idleAway = false
+			}
+		}
 		c.mu.Unlock()
+		if back {
+			c.cMngr.presenceChanged(c)
+		}
 		c.log.LogSession("received", remoteAddr, fmt.Sprintf("%s", &req))
+		if drop, gone := c.throttle(&req, remoteAddr); gone {
+			return
+		} else if drop {
+			continue
+		}
+		if rspt, reason := req.validate(lim); rspt != 0 {
+			c.sendResponse(req.RoomName, rspt, reason, nil)
+			continue
+		}
+		if req.ReqType == ChatReqTypeMsg && strings.HasPrefix(req.Content, "/") {
+			if !c.slashCommand(&req) {
+				continue
+			}
+			// The request the command stands for is held to its own limits.
+			if rspt, reason := req.validate(lim); rspt != 0 {
+				c.sendResponse(req.RoomName, rspt, reason, nil)
+				continue
+			}
+			// A command that joins a room is held to the join limit as well.
+			if req.ReqType == ChatReqTypeJoin {
+				if drop, gone := c.throttle(&req, remoteAddr); gone {
+					return
+				} else if drop {
+					continue
+				}
+			}
+		}
+		if req.isPost() {
+			content, err := c.cMngr.filterMessage(req.RoomName, c.Nickname(), req.Content)
+			if err != nil {
+				c.sendResponse(req.RoomName, ChatRspTypeErrMessageRejected, err.Error(), nil)
+				continue
+			}
+			req.Content = content
+		}
 		switch req.ReqType {
 		case ChatReqTypeSetNickname:
 			c.setNickname(&req)
@@ -93,6 +187,24 @@ func (c *Chatter) receive() {
 			c.getNickname()
 		case ChatReqTypeListRooms:
 			c.listRooms()
+		case ChatReqTypeRegister:
+			c.register(&req)
+		case ChatReqTypeIdentify:
+			c.identify(&req)
+		case ChatReqTypeDropNickname:
+			c.dropNickname(&req)
+		case ChatReqTypeSetPresence:
+			c.setPresence(&req)
+		case ChatReqTypePrivateMsg:
+			req.Who = c
+			c.privateMessage(&req)
+		case ChatReqTypeRenameRoom:
+			c.renameRoom(&req)
+		case ChatReqTypeDeleteRoom:
+			c.deleteRoom(&req)
+		case ChatReqTypeHistoryPage:
+			req.Who = c
+			c.historyPage(&req)
 		default: // Let room handle other requests or send error if no room name provided.
 			req.Who = c
 			c.sendRequestToRoom(&req)
@@ -100,10 +212,14 @@ func (c *Chatter) receive() {
 	}
 }
 
-// send is a go routine used to poll queued messages to send to the client.
+// send is a go routine used to poll queued messages to send to the client. It also pings the
+// client, and closes the connection if a ping is not answered or a send blocks too long.
 func (c *Chatter) send() {
 	defer c.wg.Done()
 	remoteAddr := fmt.Sprint(c.ws.Request().RemoteAddr)
+	ping := time.NewTimer(c.pingAfter())
+	defer ping.Stop()
+	var pong <-chan time.Time // Fires when the time to answer a ping is up.
 	for {
 		select {
 		case <-c.cMngr.done: // Server shutdown signal.
@@ -122,8 +238,13 @@ func (c *Chatter) send() {
 			c.rspCount++
 			c.mu.Unlock()
 			c.log.LogSession("sent", remoteAddr, fmt.Sprintf("%s", rsp))
+			c.setWriteDeadline()
 			if err := websocket.JSON.Send(c.ws, rsp); err != nil {
+				e, ok := err.(net.Error)
 				switch {
+				case ok && e.Timeout():
+					c.closeDead(remoteAddr, "Client forced to disconnect for not reading responses.")
+					return
 				case err.Error() == "EOF":
 					c.log.LogSession("disconnected", remoteAddr, "Client disconnected.")
 					return
@@ -131,11 +252,162 @@ func (c *Chatter) send() {
 					c.log.LogError(remoteAddr, fmt.Sprintf("Couldn't send. Error: %s", err.Error()))
 				}
 			}
+		case <-ping.C:
+			lim := c.cMngr.livenessLimits()
+			if c.conn != nil && lim.ping > 0 && pong == nil {
+				if err := c.sendPing(); err != nil {
+					if e, ok := err.(net.Error); ok && e.Timeout() {
+						c.closeDead(remoteAddr, "Client forced to disconnect for not reading responses.")
+						return
+					}
+					c.log.LogError(remoteAddr, fmt.Sprintf("Couldn't ping. Error: %s", err.Error()))
+				} else if lim.pong > 0 {
+					pong = time.After(lim.pong)
+				}
+			}
+			ping.Reset(c.pingAfter())
+		case <-pong:
+			pong = nil
+			if !c.conn.pong() {
+				c.closeDead(remoteAddr, "Client forced to disconnect for not answering pings.")
+				return
+			}
 		}
 		runtime.Gosched()
 	}
 }
 
+// pingAfter returns how long to wait before the next ping to the client.
+func (c *Chatter) pingAfter() time.Duration {
+	if p := c.cMngr.livenessLimits().ping; p > 0 {
+		return p
+	}
+	return chatterPingPoll
+}
+
+// sendPing sends a websocket ping to the client, which answers with a pong echoing its payload.
+func (c *Chatter) sendPing() error {
+	payload := c.conn.ping()
+	c.setWriteDeadline()
+	c.ws.PayloadType = websocket.PingFrame
+	defer func() { c.ws.PayloadType = websocket.TextFrame }()
+	_, err := c.ws.Write(payload)
+	return err
+}
+
+// setWriteDeadline limits how long the next send to the client may block.
+func (c *Chatter) setWriteDeadline() {
+	var t time.Time
+	if w := c.cMngr.livenessLimits().write; w > 0 {
+		t = time.Now().Add(w)
+	}
+	c.ws.SetWriteDeadline(t)
+}
+
+// closeDead logs a client that stopped responding and closes its connection without waiting on
+// it, which breaks the receive() loop and forces a chatter shutdown.
+func (c *Chatter) closeDead(remoteAddr string, reason string) {
+	c.log.LogSession("disconnected", remoteAddr, reason)
+	c.ws.SetWriteDeadline(time.Now())
+	c.ws.Close()
+}
+
+// watchAway is a go routine used to show the chatter as away once it has been idle for the away
+// time. The connection stays open until the longer idle timeout.
+func (c *Chatter) watchAway() {
+	defer c.wg.Done()
+	for {
+		select {
+		case <-c.cMngr.done: // Server shutdown signal.
+			return
+		case <-c.done: // Chatter shutdown signal.
+			return
+		case <-time.After(c.awayAfter()):
+			awayi := time.Duration(c.cMngr.AwayIdle()) * time.Second
+			c.mu.Lock()
+			away := awayi > 0 && c.presence == chatterPresenceOnline && time.Since(c.active) >= awayi
+			if away {
+				c.presence = chatterPresenceAway
+				c.idleAway = true
+			}
+			c.mu.Unlock()
+			if away {
+				c.cMngr.presenceChanged(c)
+			}
+		}
+	}
+}
+
+// awayAfter returns how long to wait before checking again whether the chatter is idle enough to
+// be shown as away.
+func (c *Chatter) awayAfter() time.Duration {
+	awayi := time.Duration(c.cMngr.AwayIdle()) * time.Second
+	if awayi <= 0 {
+		return chatterAwayPoll
+	}
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.presence != chatterPresenceOnline {
+		return awayi
+	}
+	return c.active.Add(awayi).Sub(time.Now())
+}
+
+// throttle applies the rate limits to a request. It returns true to drop the request, and gone
+// if the chatter flooded and has been disconnected and shut down.
+func (c *Chatter) throttle(r *ChatRequest, remoteAddr string) (drop bool, gone bool) {
+	drop, kick := c.rateLimit(r)
+	if kick {
+		c.log.LogSession("disconnected", remoteAddr, "Client disconnected for flooding.")
+		c.disconnect(ChatRspTypeErrThrottled, "Disconnected for sending too many requests.")
+		c.shutDown()
+	}
+	return drop, kick
+}
+
+// rateLimit applies the message and join rate limits to a request. A throttled request is dropped
+// and the chatter is told, unless it has been throttled too often and must be disconnected.
+func (c *Chatter) rateLimit(r *ChatRequest) (drop bool, kick bool) {
+	lim := c.cMngr.rateLimits()
+	now := time.Now()
+	c.mu.Lock()
+	var allowed bool
+	var what string
+	switch r.ReqType {
+	case ChatReqTypeMsg, ChatReqTypePrivateMsg, ChatReqTypeEmote:
+		if !c.msgLimit.matches(float64(lim.msgRate), lim.msgBurst) {
+			c.msgLimit = rateLimiterNew(float64(lim.msgRate), lim.msgBurst, now)
+		}
+		allowed, what = c.msgLimit.allow(now), "sending messages"
+	case ChatReqTypeJoin:
+		rate := float64(lim.joinRate) / 60
+		if !c.joinLimit.matches(rate, lim.joinRate) {
+			c.joinLimit = rateLimiterNew(rate, lim.joinRate, now)
+		}
+		allowed, what = c.joinLimit.allow(now), "joining rooms"
+	default:
+		allowed = true
+	}
+	if allowed {
+		c.mu.Unlock()
+		return false, false
+	}
+	c.throttled++
+	if now.Sub(c.strikeAt) > chatterThrottleWindow {
+		c.strikeAt = now
+		c.strikes = 0
+	}
+	c.strikes++
+	kick = lim.maxThrottle > 0 && c.strikes > lim.maxThrottle
+	c.mu.Unlock()
+	c.cMngr.incrThrottled(kick)
+	if !kick {
+		c.sendResponse(r.RoomName, ChatRspTypeErrThrottled,
+			fmt.Sprintf("You are %s too fast. Request dropped.", what), nil)
+	}
+	return true, kick
+}
+
 // shutDown shuts down sending/receiving.
 func (c *Chatter) shutDown() {
 	close(c.done) // Signal to send() and rooms we are quitting.
@@ -149,12 +421,183 @@ func (c *Chatter) setNickname(r *ChatRequest) {
 		c.sendResponse("", ChatRspTypeErrNicknameMandatory, "nickname cannot be blank", nil)
 		return
 	}
-	c.mu.RLock()
-	c.nickname = r.Content
-	c.mu.RUnlock()
+	if c.isLocked() {
+		c.sendResponse("", ChatRspTypeErrNicknameLocked,
+			fmt.Sprintf(`Nickname is locked to "%s".`, c.Nickname()), nil)
+		return
+	}
+	if c.cMngr.Nicknames().isRegistered(r.Content) && !c.isIdentified(r.Content) {
+		c.sendResponse("", ChatRspTypeErrNicknameRegistered,
+			fmt.Sprintf(`Nickname "%s" is registered. Identify to use it.`, r.Content), nil)
+		return
+	}
+	if room, err := c.cMngr.renameChatter(c, r.Content); err != nil {
+		c.sendNicknameUsed(r.Content, room, err)
+		return
+	}
 	c.sendResponse("", ChatRspTypeSetNickname, fmt.Sprintf(`Nickname set to "%s".`, c.Nickname()), nil)
 }
 
+// sendNicknameUsed tells the chatter a nickname is banned or in use in a room, or on the server if
+// no room is given.
+func (c *Chatter) sendNicknameUsed(name string, room *ChatRoom, err error) {
+	if err == chatManagerErrNicknameBanned {
+		c.sendResponse("", ChatRspTypeErrBanned, fmt.Sprintf(`Nickname "%s" is banned from the server.`, name), nil)
+		return
+	}
+	if room == nil {
+		c.sendResponse("", ChatRspTypeErrNicknameUsed,
+			fmt.Sprintf(`Nickname "%s" is already in use on the server.`, name), nil)
+		return
+	}
+	c.sendResponse(room.Name(), ChatRspTypeErrNicknameUsed,
+		fmt.Sprintf(`Nickname "%s" is already in use in room "%s".`, name, room.Name()), nil)
+}
+
+// setName changes the nickname of the chatter. Use ChatManager.renameChatter so rooms are told.
+func (c *Chatter) setName(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nickname = name
+}
+
+// lockNickname sets the nickname to an authenticated identity that cannot be changed.
+func (c *Chatter) lockNickname(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.nickname = name
+	c.locked = true
+}
+
+// isLocked validates whether the nickname is locked to an authenticated identity.
+func (c *Chatter) isLocked() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.locked
+}
+
+// register claims the nickname of the chatter with a password so no other chatter can use it.
+func (c *Chatter) register(r *ChatRequest) {
+	name := c.Nickname()
+	if name == "" {
+		c.sendResponse("", ChatRspTypeErrNicknameMandatory, "nickname must be set before it is registered", nil)
+		return
+	}
+	if r.Password == "" {
+		c.sendResponse("", ChatRspTypeErrPasswordMandatory, "password is mandatory", nil)
+		return
+	}
+	err := c.cMngr.Nicknames().register(name, r.Password)
+	switch {
+	case err == nicknameRegistryErrRegistered:
+		c.sendResponse("", ChatRspTypeErrNicknameRegistered,
+			fmt.Sprintf(`Nickname "%s" is already registered.`, name), nil)
+		return
+	case !c.sendRegistryError(name, err):
+		return
+	}
+	c.prove(name)
+	c.sendResponse("", ChatRspTypeRegister, fmt.Sprintf(`Nickname "%s" registered.`, name), nil)
+}
+
+// identify validates the password of a registered nickname and sets it as the nickname of the
+// chatter. A blank content identifies for the current nickname.
+func (c *Chatter) identify(r *ChatRequest) {
+	name := r.Content
+	if name == "" {
+		name = c.Nickname()
+	}
+	if name == "" {
+		c.sendResponse("", ChatRspTypeErrNicknameMandatory, "nickname cannot be blank", nil)
+		return
+	}
+	if r.Password == "" {
+		c.sendResponse("", ChatRspTypeErrPasswordMandatory, "password is mandatory", nil)
+		return
+	}
+	if c.isLocked() && name != c.Nickname() {
+		c.sendResponse("", ChatRspTypeErrNicknameLocked,
+			fmt.Sprintf(`Nickname is locked to "%s".`, c.Nickname()), nil)
+		return
+	}
+	if !c.sendRegistryError(name, c.cMngr.Nicknames().identify(name, r.Password)) {
+		return
+	}
+	if room, err := c.cMngr.renameChatter(c, name); err != nil {
+		c.sendNicknameUsed(name, room, err)
+		return
+	}
+	c.prove(name)
+	c.sendResponse("", ChatRspTypeIdentify, fmt.Sprintf(`Identified as "%s".`, name), nil)
+}
+
+// dropNickname releases the registration of the nickname of the chatter.
+func (c *Chatter) dropNickname(r *ChatRequest) {
+	name := c.Nickname()
+	if r.Password == "" {
+		c.sendResponse("", ChatRspTypeErrPasswordMandatory, "password is mandatory", nil)
+		return
+	}
+	if !c.sendRegistryError(name, c.cMngr.Nicknames().drop(name, r.Password)) {
+		return
+	}
+	c.mu.Lock()
+	c.ident = ""
+	c.mu.Unlock()
+	c.sendResponse("", ChatRspTypeDropNickname, fmt.Sprintf(`Nickname "%s" dropped.`, name), nil)
+}
+
+// sendRegistryError sends the chatter the response for a nickname registry error. It returns
+// true if the request may continue.
+func (c *Chatter) sendRegistryError(name string, err error) bool {
+	switch err {
+	case nil:
+		return true
+	case nicknameRegistryErrNotRegistered:
+		c.sendResponse("", ChatRspTypeErrNicknameNotRegistered,
+			fmt.Sprintf(`Nickname "%s" is not registered.`, name), nil)
+		return false
+	case nicknameRegistryErrWrongPassword:
+		c.sendResponse("", ChatRspTypeErrWrongPassword,
+			fmt.Sprintf(`Wrong password for nickname "%s".`, name), nil)
+		return false
+	case nicknameRegistryErrPasswordTooLong:
+		c.sendResponse("", ChatRspTypeErrTooLong, err.Error(), nil)
+		return false
+	default: // The change is kept in memory if only the save failed.
+		c.log.Errorf("Nickname registry error for \"%s\": %s", name, err.Error())
+		return true
+	}
+}
+
+// prove sets the registered nickname the chatter has identified for and moves the room roles it
+// holds by its connection to the nickname.
+func (c *Chatter) prove(name string) {
+	c.mu.Lock()
+	c.ident = name
+	c.mu.Unlock()
+	c.cMngr.proveChatter(c)
+}
+
+// identity returns the identity the chatter has proven: the authenticated nickname it is locked
+// to, the registered nickname it identified for, or the nickname of a bot run by the server. It is
+// blank if the chatter has not proven one.
+func (c *Chatter) identity() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.locked || c.isBot() {
+		return c.nickname
+	}
+	return c.ident
+}
+
+// isIdentified validates whether the chatter has identified for a registered nickname.
+func (c *Chatter) isIdentified(name string) bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.ident == name
+}
+
 // getNickname returns the nickname for the chatter via the response queue.
 func (c *Chatter) getNickname() {
 	c.sendResponse("", ChatRspTypeGetNickname, c.Nickname(), nil)
@@ -167,11 +610,184 @@ func (c *Chatter) Nickname() string {
 	return c.nickname
 }
 
+// setPresence sets the presence of the chatter and tells the rooms it is in. Content is "online",
+// "away", "busy" or custom text, and no content is online.
+func (c *Chatter) setPresence(r *ChatRequest) {
+	presence := r.Content
+	if presence == "" {
+		presence = chatterPresenceOnline
+	}
+	c.mu.Lock()
+	c.presence = presence
+	c.idleAway = false
+	c.mu.Unlock()
+	c.sendResponse("", ChatRspTypePresence, fmt.Sprintf(`Presence set to "%s".`, presence), nil)
+	c.cMngr.presenceChanged(c)
+}
+
+// Presence returns the presence of the chatter.
+func (c *Chatter) Presence() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.presence
+}
+
+// isAdmin validates whether the proven identity of the chatter may administer any room on the
+// server.
+func (c *Chatter) isAdmin() bool {
+	return c.cMngr.isAdmin(c.identity())
+}
+
+// remoteAddr returns the IP address and port of the remote client.
+func (c *Chatter) remoteAddr() string {
+	if c.ws == nil {
+		return ""
+	}
+	return c.ws.Request().RemoteAddr
+}
+
+// disconnect tells the chatter why it is being disconnected and closes the connection. The
+// receive() loop then shuts the chatter down.
+func (c *Chatter) disconnect(rspt int, cont string) {
+	if c.ws == nil {
+		return
+	}
+	if rsp, err := ChatResponseNew("", rspt, cont, []string{}); err == nil {
+		c.setWriteDeadline()
+		websocket.JSON.Send(c.ws, rsp)
+	}
+	c.ws.Close()
+}
+
+// remoteIP returns the IP address of the remote client.
+func (c *Chatter) remoteIP() string {
+	addr := c.remoteAddr()
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
+
 // listRooms returns a list of chat rooms to the chatter.
 func (c *Chatter) listRooms() {
 	c.sendResponse("", ChatRspTypeListRooms, "", c.cMngr.list())
 }
 
+// privateMessage sends a message to a single chatter. With a room name the recipient is looked up
+// in the room, otherwise anywhere on the server.
+func (c *Chatter) privateMessage(r *ChatRequest) {
+	if r.Target == "" {
+		c.sendResponse(r.RoomName, ChatRspTypeErrNicknameMandatory, "target nickname is mandatory", nil)
+		return
+	}
+	if r.RoomName != "" {
+		m, err := c.cMngr.find(r.RoomName)
+		if err != nil {
+			c.sendResponse(r.RoomName, ChatRspTypeErrRoomUnavailable, err.Error(), nil)
+			return
+		}
+		c.sendRequestSafety(m, r)
+		return
+	}
+	to, err := c.cMngr.findChatter(r.Target)
+	switch {
+	case err == chatManagerErrNicknameAmbiguous:
+		c.sendResponse("", ChatRspTypeErrNicknameAmbiguous,
+			fmt.Sprintf(`Nickname "%s" is used by more than one chatter.`, r.Target), nil)
+	case err != nil:
+		c.sendResponse("", ChatRspTypeErrNicknameUnknown,
+			fmt.Sprintf(`Nickname "%s" is not on the server.`, r.Target), nil)
+	default:
+		cont := fmt.Sprintf("%s: %s", c.Nickname(), r.Content)
+		l := []string{c.Nickname(), to.Nickname()}
+		c.sendResponse("", ChatRspTypePrivateMsg, cont, l)
+		if to != c {
+			to.sendResponse("", ChatRspTypePrivateMsg, cont, l)
+		}
+	}
+}
+
+// renameRoom changes the name of a room owned by the chatter when no one else is in it.
+func (c *Chatter) renameRoom(r *ChatRequest) {
+	if r.RoomName == "" || r.Content == "" {
+		c.sendResponse(r.RoomName, ChatRspTypeErrRoomMandatory, "room name and new name are mandatory", nil)
+		return
+	}
+	if !c.authorizeRoom(r.RoomName) {
+		return
+	}
+	if err := c.cMngr.renameRoom(r.RoomName, r.Content, c); err != nil {
+		c.sendRoomError(r.RoomName, err)
+		return
+	}
+	c.sendResponse(r.Content, ChatRspTypeRenameRoom,
+		fmt.Sprintf(`Room "%s" renamed to "%s".`, r.RoomName, r.Content), nil)
+}
+
+// deleteRoom removes a room owned by the chatter when no one else is in it.
+func (c *Chatter) deleteRoom(r *ChatRequest) {
+	if r.RoomName == "" {
+		c.sendResponse("", ChatRspTypeErrRoomMandatory, "room name is mandatory to access a room", nil)
+		return
+	}
+	if !c.authorizeRoom(r.RoomName) {
+		return
+	}
+	if err := c.cMngr.deleteRoom(r.RoomName, c); err != nil {
+		c.sendRoomError(r.RoomName, err)
+		return
+	}
+	c.sendResponse(r.RoomName, ChatRspTypeDeleteRoom, fmt.Sprintf(`Room "%s" deleted.`, r.RoomName), nil)
+}
+
+// historyPage sends the request for a page of history to an existing room. Rooms are not created.
+func (c *Chatter) historyPage(r *ChatRequest) {
+	if r.RoomName == "" {
+		c.sendResponse("", ChatRspTypeErrRoomMandatory, "room name is mandatory to access a room", nil)
+		return
+	}
+	room, err := c.cMngr.find(r.RoomName)
+	if err != nil {
+		c.sendRoomError(r.RoomName, err)
+		return
+	}
+	c.sendRequestSafety(room, r)
+}
+
+// authorizeRoom validates the chatter is in the room and is the owner of the room or a server admin.
+func (c *Chatter) authorizeRoom(name string) bool {
+	room, err := c.cMngr.find(name)
+	if err != nil {
+		c.sendRoomError(name, err)
+		return false
+	}
+	if !room.isMember(c) {
+		c.sendResponse(name, ChatRspTypeErrNotMember, fmt.Sprintf(`You are not a member of room "%s".`, name), nil)
+		return false
+	}
+	if !room.isOwner(c) && !c.isAdmin() {
+		c.sendResponse(name, ChatRspTypeErrNotAuthorized,
+			fmt.Sprintf(`You are not authorized to administer room "%s".`, name), nil)
+		return false
+	}
+	return true
+}
+
+// sendRoomError sends the chatter the response for a chat manager room error.
+func (c *Chatter) sendRoomError(name string, err error) {
+	switch err {
+	case chatManagerErrRoomNotFound:
+		c.sendResponse(name, ChatRspTypeErrRoomNotFound, err.Error(), nil)
+	case chatManagerErrRoomExists:
+		c.sendResponse(name, ChatRspTypeErrRoomExists, err.Error(), nil)
+	case chatManagerErrRoomNotEmpty:
+		c.sendResponse(name, ChatRspTypeErrRoomNotEmpty, err.Error(), nil)
+	default:
+		c.sendResponse(name, ChatRspTypeErrMaxRoomsReached, err.Error(), nil)
+	}
+}
+
 // ChatterStats is a simple structure for returning statistic information on the chatter.
 type ChatterStats struct {
 	Nickname   string    `json:"nickname"`   // The nickname of the chatter.
@@ -181,6 +797,10 @@ type ChatterStats struct {
 	LastRsp    time.Time `json:"lastRsp"`    // The last response time to the chatter.
 	ReqCount   uint64    `json:"reqcount"`   // Total requests received.
 	RspCount   uint64    `json:"rspCount"`   // Total responses sent.
+	Throttled  uint64    `json:"throttled"`  // Total requests dropped by rate limits.
+	Bot        bool      `json:"bot"`        // Is the chatter a bot running inside the server?
+	Presence   string    `json:"presence"`   // Online, away, busy or custom text.
+	RTT        float64   `json:"rttMs"`      // The round trip time of the last ping echoed in milliseconds.
 }
 
 // ChatterStatsNew returns status information on the chatter.
@@ -189,12 +809,16 @@ func (c *Chatter) ChatterStatsNew() *ChatterStats {
 	defer c.mu.RUnlock()
 	return &ChatterStats{
 		Nickname:   c.nickname,
-		RemoteAddr: c.ws.Request().RemoteAddr,
+		RemoteAddr: c.remoteAddr(),
 		Start:      c.start,
 		LastReq:    c.lastReq,
 		LastRsp:    c.lastRsp,
 		ReqCount:   c.reqCount,
 		RspCount:   c.rspCount,
+		Throttled:  c.throttled,
+		Bot:        c.isBot(),
+		Presence:   c.presence,
+		RTT:        c.conn.roundTrip().Seconds() * 1000,
 	}
 }
 
@@ -204,7 +828,7 @@ func (c *Chatter) sendRequestToRoom(r *ChatRequest) {
 		c.sendResponse("", ChatRspTypeErrRoomMandatory, "room name is mandatory to access a room", nil)
 		return
 	}
-	m, err := c.cMngr.findCreate(r.RoomName)
+	m, err := c.cMngr.findCreate(r.RoomName, c)
 	if err != nil {
 		c.sendResponse(r.RoomName, ChatRspTypeErrMaxRoomsReached, err.Error(), nil)
 		return
@@ -228,10 +852,15 @@ func (c *Chatter) sendResponse(rname string, rspt int, cont string, l []string)
 		l = []string{}
 	}
 	if rsp, err := ChatResponseNew(rname, rspt, cont, l); err == nil {
-		select {
-		case <-c.done:
-		default:
-			c.rspq <- rsp
-		}
+		c.queueResponse(rsp)
+	}
+}
+
+// queueResponse places a prepared response on the queue for the send() go routine.
+func (c *Chatter) queueResponse(rsp *ChatResponse) {
+	select {
+	case <-c.done:
+	default:
+		c.rspq <- rsp
 	}
 }
@@ -1,14 +1,32 @@
 package server
 
 const (
-	version         = "0.1.0"     // Application and server version.
-	DefaultHostname = "localhost" // The hostname of the server.
-	DefaultPort     = 6660        // Port to receive requests: see IANA Port Numbers.
-	DefaultProfPort = 0           // Profiler port to receive requests. *
-	DefaultMaxConns = 0           // Maximum number of connections allowed. *
-	DefaultMaxRooms = 0           // Maximum number of chat rooms allowed. *
-	DefaultMaxIdle  = 0           // Maximum idle seconds per user connection. *
-	DefaultMaxProcs = 0           // Maximum number of computer processors to utilize. *
+	version            = "0.1.0"     // Application and server version.
+	DefaultHostname    = "localhost" // The hostname of the server.
+	DefaultPort        = 6660        // Port to receive requests: see IANA Port Numbers.
+	DefaultProfPort    = 0           // Profiler port to receive requests. *
+	DefaultMaxConns    = 0           // Maximum number of connections allowed. *
+	DefaultMaxRooms    = 0           // Maximum number of chat rooms allowed. *
+	DefaultMaxIdle     = 0           // Maximum idle seconds per user connection. *
+	DefaultMaxProcs    = 0           // Maximum number of computer processors to utilize. *
+	DefaultMaxHist     = 0           // Maximum number of messages replayed to a joining chatter. *
+	DefaultHistDir     = ""          // Directory where the history of each room is stored (empty = not stored).
+	DefaultNickFile    = ""          // File where registered nicknames are stored (empty = memory only).
+	DefaultMsgRate     = 0           // Messages per second allowed to each chatter. *
+	DefaultMsgBurst    = 0           // Messages allowed at once to each chatter before the rate applies. *
+	DefaultJoinRate    = 0           // Room joins per minute allowed to each chatter. *
+	DefaultMaxThrottle = 0           // Throttled requests per minute before a chatter is disconnected. *
+	DefaultMaxFrame    = 65536       // Maximum bytes in a request frame. *
+	DefaultMaxMsgLen   = 4096        // Maximum characters in a message. *
+	DefaultMaxNickLen  = 32          // Maximum characters in a nickname. *
+	DefaultMaxRoomLen  = 64          // Maximum characters in a room name. *
+	DefaultGrace       = 5           // Seconds allowed on shutdown for queues to drain.
+	DefaultReconnect   = 10          // Seconds chatters are asked to wait before reconnecting after a shutdown.
+	DefaultTypingTTL   = 5           // Seconds a typing notice lasts without a stop. *
+	DefaultAwayIdle    = 0           // Idle seconds before a chatter is shown as away. *
+	DefaultPingPeriod  = 30          // Seconds between pings to each chatter. *
+	DefaultPongWait    = 10          // Seconds a chatter has to answer a ping before it is disconnected. *
+	DefaultWriteWait   = 10          // Seconds a send to a chatter may block before it is disconnected. *
 
 	// * zeros = no change or no limitation or not enabled.
 
@@ -16,4 +34,6 @@ const (
 	wsRouteV1Conn    = "/v1.0/chat"
 	httpRouteV1Alive = "/v1.0/alive"
 	httpRouteV1Stats = "/v1.0/stats"
+	httpRouteV1Admin = "/v1.0/admin/"
+	httpRouteMetrics = "/metrics"
 )
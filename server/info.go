@@ -4,16 +4,21 @@ import "encoding/json"
 
 // Info provides basic config information to/about the running server.
 type Info struct {
-	Version  string `json:"version"`      // Version of the server.
-	Name     string `json:"name"`         // The name of the server.
-	Hostname string `json:"hostname"`     // The hostname of the server.
-	UUID     string `json:"UUID"`         // Unique ID of the server.
-	Port     int    `json:"port"`         // Port the server is listening on.
-	ProfPort int    `json:"profPort"`     // Profiler port the server is listening on.
-	MaxConns int    `json:"maxConns"`     // The maximum concurrent clients accepted.
-	MaxRooms int    `json:"maxRooms"`     // The maximum number of chat rooms allowed.
-	MaxIdle  int    `json:"maxIdle"`      // The maximum client idle time in seconds before disconnect.
-	Debug    bool   `json:"debugEnabled"` // Is debugging enabled on the server.
+	Version     string `json:"version"`      // Version of the server.
+	Name        string `json:"name"`         // The name of the server.
+	Hostname    string `json:"hostname"`     // The hostname of the server.
+	UUID        string `json:"UUID"`         // Unique ID of the server.
+	Port        int    `json:"port"`         // Port the server is listening on.
+	ProfPort    int    `json:"profPort"`     // Profiler port the server is listening on.
+	MaxConns    int    `json:"maxConns"`     // The maximum concurrent clients accepted.
+	MaxRooms    int    `json:"maxRooms"`     // The maximum number of chat rooms allowed.
+	MaxIdle     int    `json:"maxIdle"`      // The maximum client idle time in seconds before disconnect.
+	MaxHist     int    `json:"maxHistory"`   // The maximum number of messages replayed on join.
+	MsgRate     int    `json:"msgRate"`      // The messages per second allowed to each chatter.
+	MsgBurst    int    `json:"msgBurst"`     // The messages allowed at once to each chatter.
+	JoinRate    int    `json:"joinRate"`     // The room joins per minute allowed to each chatter.
+	MaxThrottle int    `json:"maxThrottles"` // The throttled requests per minute before a disconnect.
+	Debug       bool   `json:"debugEnabled"` // Is debugging enabled on the server.
 }
 
 // InfoNew is a factory function that returns a new instance of Info.
@@ -9,7 +9,8 @@ import (
 const (
 	testInfoExpectedJSONResult = `{"version":"9.8.7","name":"Test Server","hostname":"0.0.0.0",` +
 		`"UUID":"ABCDEFGHIJKLMNOPQRSTUVWXYZ","port":6661,"profPort":6061,"maxConns":999,` +
-		`"maxRooms":888,"maxIdle":777,"debugEnabled":true}`
+		`"maxRooms":888,"maxIdle":777,"maxHistory":666,"msgRate":5,"msgBurst":10,"joinRate":20,` +
+		`"maxThrottles":3,"debugEnabled":true}`
 )
 
 func TestInfoNew(t *testing.T) {
@@ -23,6 +24,11 @@ func TestInfoNew(t *testing.T) {
 		i.MaxConns = 999
 		i.MaxRooms = 888
 		i.MaxIdle = 777
+		i.MaxHist = 666
+		i.MsgRate = 5
+		i.MsgBurst = 10
+		i.JoinRate = 20
+		i.MaxThrottle = 3
 		i.Debug = true
 	})
 	tp := reflect.TypeOf(info)
@@ -55,6 +61,11 @@ func TestInfoString(t *testing.T) {
 		i.MaxConns = 999
 		i.MaxRooms = 888
 		i.MaxIdle = 777
+		i.MaxHist = 666
+		i.MsgRate = 5
+		i.MsgBurst = 10
+		i.JoinRate = 20
+		i.MaxThrottle = 3
 		i.Debug = true
 	})
 	actual := fmt.Sprint(info)
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	messageFilterLinks       = regexp.MustCompile(`(?i)\b(?:https?://|www\.)\S+`) // Links stripped by the link filter.
+	messageFilterLinkRemoved = "[link removed]"                                   // What a stripped link is replaced with.
+	messageFilterWordChar    = regexp.MustCompile(`^\w$`)                         // A character a word boundary can follow.
+
+	messageFilterErrBlocked = errors.New("Message contains blocked content.")
+)
+
+// MessageFilter inspects each message posted by a chatter before it reaches a room. A filter may
+// pass the content on unchanged, rewrite it, or reject the message with an error whose text is
+// returned to the sender. Filters run in the order they are registered and each receives the
+// content returned by the one before.
+type MessageFilter interface {
+	Filter(room string, nickname string, content string) (string, error)
+}
+
+// MessageFilterFunc adapts an ordinary function to the MessageFilter interface.
+type MessageFilterFunc func(room string, nickname string, content string) (string, error)
+
+// Filter is an implementation of the MessageFilter interface.
+func (f MessageFilterFunc) Filter(room string, nickname string, content string) (string, error) {
+	return f(room, nickname, content)
+}
+
+// WordFilter masks words in messages with asterisks, ex: for profanity.
+type WordFilter struct {
+	re *regexp.Regexp // Matches any of the words.
+}
+
+// WordFilterNew is a factory function that returns a filter masking the words, ignoring case.
+func WordFilterNew(words []string) *WordFilter {
+	var quoted []string
+	for _, w := range words {
+		if w = strings.TrimSpace(w); w != "" {
+			quoted = append(quoted, wordFilterBound(w[:1])+regexp.QuoteMeta(w)+wordFilterBound(w[len(w)-1:]))
+		}
+	}
+	if len(quoted) == 0 {
+		return &WordFilter{}
+	}
+	return &WordFilter{regexp.MustCompile(`(?i)(?:` + strings.Join(quoted, "|") + `)`)}
+}
+
+// wordFilterBound returns a word boundary if a word starts or ends with the character, so words
+// are not masked inside longer words.
+func wordFilterBound(c string) string {
+	if messageFilterWordChar.MatchString(c) {
+		return `\b`
+	}
+	return ""
+}
+
+// WordFilterLoad returns a filter masking the words in a file, one word per line. Blank lines and
+// lines starting with # are ignored.
+func WordFilterLoad(path string) (*WordFilter, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var words []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if w := strings.TrimSpace(scanner.Text()); w != "" && !strings.HasPrefix(w, "#") {
+			words = append(words, w)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return WordFilterNew(words), nil
+}
+
+// Filter is an implementation of the MessageFilter interface.
+func (f *WordFilter) Filter(room string, nickname string, content string) (string, error) {
+	if f.re == nil {
+		return content, nil
+	}
+	return f.re.ReplaceAllStringFunc(content, func(w string) string {
+		return strings.Repeat("*", utf8.RuneCountInString(w))
+	}), nil
+}
+
+// LinkFilter replaces links in messages with a notice they were removed.
+type LinkFilter struct{}
+
+// Filter is an implementation of the MessageFilter interface.
+func (f *LinkFilter) Filter(room string, nickname string, content string) (string, error) {
+	return messageFilterLinks.ReplaceAllString(content, messageFilterLinkRemoved), nil
+}
+
+// RegexFilter rejects messages matching any of its patterns.
+type RegexFilter struct {
+	patterns []*regexp.Regexp // The blocked patterns.
+}
+
+// RegexFilterNew is a factory function that returns a filter blocking messages matching any of the
+// regular expressions.
+func RegexFilterNew(patterns []string) (*RegexFilter, error) {
+	f := &RegexFilter{}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, err
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	return f, nil
+}
+
+// Filter is an implementation of the MessageFilter interface.
+func (f *RegexFilter) Filter(room string, nickname string, content string) (string, error) {
+	for _, re := range f.patterns {
+		if re.MatchString(content) {
+			return "", messageFilterErrBlocked
+		}
+	}
+	return content, nil
+}
+
+// messageFiltersNew returns the built-in filters enabled by the options: blocked patterns first,
+// then link stripping and word masking. Filters that cannot be loaded are left out and the error
+// returned.
+func messageFiltersNew(ops *Options) ([]MessageFilter, error) {
+	var filters []MessageFilter
+	var err error
+	if len(ops.Blocks) > 0 {
+		var f *RegexFilter
+		if f, err = RegexFilterNew(ops.Blocks); err == nil {
+			filters = append(filters, f)
+		}
+	}
+	if ops.StripLinks {
+		filters = append(filters, &LinkFilter{})
+	}
+	if ops.WordFile != "" {
+		f, ferr := WordFilterLoad(ops.WordFile)
+		if ferr != nil {
+			return filters, ferr
+		}
+		filters = append(filters, f)
+	}
+	return filters, err
+}
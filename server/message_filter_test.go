@@ -0,0 +1,120 @@
+package server
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWordFilter(t *testing.T) {
+	t.Parallel()
+	f := WordFilterNew([]string{"darn", " heck ", "", "c++"})
+	tests := []struct {
+		in  string
+		exp string
+	}{
+		{"Darn it!", "**** it!"},
+		{"What the HECK.", "What the ****."},
+		{"Darnation is fine.", "Darnation is fine."},
+		{"I like c++ a lot.", "I like *** a lot."},
+	}
+	for _, tc := range tests {
+		if out, err := f.Filter("room", "nick", tc.in); err != nil || out != tc.exp {
+			t.Errorf("Word filter incorrect.\nExpected: %s\n\nActual: %s %v\n", tc.exp, out, err)
+		}
+	}
+	if out, _ := WordFilterNew(nil).Filter("room", "nick", "darn"); out != "darn" {
+		t.Errorf("Empty word filter should pass content unchanged. Actual: %s", out)
+	}
+}
+
+func TestWordFilterLoad(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "chattypantz")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %s", err.Error())
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "words.txt")
+	if err := ioutil.WriteFile(path, []byte("# Masked words.\ndarn\n\nheck\n"), 0600); err != nil {
+		t.Fatalf("Cannot write word file: %s", err.Error())
+	}
+	f, err := WordFilterLoad(path)
+	if err != nil {
+		t.Fatalf("Word file should load: %s", err.Error())
+	}
+	if out, _ := f.Filter("room", "nick", "darn # heck"); out != "**** # ****" {
+		t.Errorf("Loaded word filter incorrect. Actual: %s", out)
+	}
+	if _, err := WordFilterLoad(filepath.Join(dir, "missing.txt")); err == nil {
+		t.Errorf("Missing word file should return an error.")
+	}
+}
+
+func TestLinkFilter(t *testing.T) {
+	t.Parallel()
+	f := &LinkFilter{}
+	out, _ := f.Filter("room", "nick", "See https://example.com/a?b=c and www.example.org now.")
+	if exp := "See [link removed] and [link removed] now."; out != exp {
+		t.Errorf("Link filter incorrect.\nExpected: %s\n\nActual: %s\n", exp, out)
+	}
+}
+
+func TestRegexFilter(t *testing.T) {
+	t.Parallel()
+	if _, err := RegexFilterNew([]string{"("}); err == nil {
+		t.Errorf("Invalid pattern should return an error.")
+	}
+	f, err := RegexFilterNew([]string{`(?i)buy\s+now`, `^\d+$`})
+	if err != nil {
+		t.Fatalf("Valid patterns should compile: %s", err.Error())
+	}
+	if _, err := f.Filter("room", "nick", "BUY   now!"); err != messageFilterErrBlocked {
+		t.Errorf("Matching message should be blocked. Actual: %v", err)
+	}
+	if out, err := f.Filter("room", "nick", "Hello 123"); err != nil || out != "Hello 123" {
+		t.Errorf("Message not matching should pass. Actual: %s %v", out, err)
+	}
+}
+
+func TestMessageFiltersNew(t *testing.T) {
+	t.Parallel()
+	if filters, err := messageFiltersNew(&Options{}); err != nil || len(filters) != 0 {
+		t.Errorf("No filters should be enabled by default. Actual: %d %v", len(filters), err)
+	}
+	filters, err := messageFiltersNew(&Options{StripLinks: true, Blocks: []string{"spam"},
+		WordFile: "/nonexistent/words.txt"})
+	if err == nil || len(filters) != 2 {
+		t.Errorf("Filters that load should be kept with the error. Actual: %d %v", len(filters), err)
+	}
+	if _, ok := filters[0].(*RegexFilter); !ok {
+		t.Errorf("Blocked patterns should be filtered first.")
+	}
+}
+
+func TestChatManagerFilterMessage(t *testing.T) {
+	t.Parallel()
+	m := &ChatManager{}
+	m.SetMessageFilters([]MessageFilter{&LinkFilter{}})
+	var got string
+	m.AddMessageFilter(MessageFilterFunc(func(room string, nickname string, content string) (string, error) {
+		got = room + " " + nickname + " " + content
+		return content + "!", nil
+	}))
+	out, err := m.filterMessage("Room", "Nick", "see www.example.com")
+	if exp := "Room Nick see [link removed]"; got != exp {
+		t.Errorf("Added filter should receive the output of the filter before.\nExpected: %s\n\nActual: %s\n",
+			exp, got)
+	}
+	if exp := "see [link removed]!"; err != nil || out != exp {
+		t.Errorf("Filtered message incorrect.\nExpected: %s\n\nActual: %s %v\n", exp, out, err)
+	}
+	m.AddMessageFilter(MessageFilterFunc(func(room string, nickname string, content string) (string, error) {
+		return "", errors.New("No shouting.")
+	}))
+	if _, err := m.filterMessage("Room", "Nick", "HI"); err == nil || err.Error() != "No shouting." {
+		t.Errorf("Rejected message should return the filter error. Actual: %v", err)
+	}
+}
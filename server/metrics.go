@@ -0,0 +1,215 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	metricsContentType = "text/plain; version=0.0.4; charset=utf-8" // Prometheus text exposition format.
+	metricsPrefix      = "chattypantz_"                             // The prefix of every metric name.
+)
+
+var (
+	// The upper bounds in seconds of the broadcast latency histogram buckets.
+	metricsBroadcastBuckets = []float64{.00001, .00005, .0001, .0005, .001, .005, .01, .05, .1, .5, 1}
+)
+
+// metricsHistogram counts observations into cumulative buckets for a Prometheus histogram.
+type metricsHistogram struct {
+	mu     sync.Mutex // Lock for update.
+	bounds []float64  // The upper bound of each bucket.
+	counts []uint64   // The observations in each bucket, not cumulative.
+	sum    float64    // The sum of all observations.
+	count  uint64     // The number of observations.
+}
+
+// metricsHistogramNew is a factory function that returns a new histogram with the bucket bounds.
+func metricsHistogramNew(bounds []float64) *metricsHistogram {
+	return &metricsHistogram{
+		bounds: bounds,
+		counts: make([]uint64, len(bounds)),
+	}
+}
+
+// observe records one observation.
+func (h *metricsHistogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i := sort.SearchFloat64s(h.bounds, v); i < len(h.bounds) {
+		h.counts[i]++
+	}
+	h.sum += v
+	h.count++
+}
+
+// observeSince records the seconds elapsed since a start time.
+func (h *metricsHistogram) observeSince(start time.Time) {
+	h.observe(time.Since(start).Seconds())
+}
+
+// snapshot returns a copy of the histogram that can be written without holding the lock.
+func (h *metricsHistogram) snapshot() *metricsHistogram {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return &metricsHistogram{
+		bounds: h.bounds,
+		counts: append([]uint64(nil), h.counts...),
+		sum:    h.sum,
+		count:  h.count,
+	}
+}
+
+// chatRoomMetrics is a snapshot of the counters of a room for the metrics route.
+type chatRoomMetrics struct {
+	name      string            // The name of the room.
+	members   int               // The number of chatters in the room.
+	reqCount  uint64            // Total requests received.
+	rspCount  uint64            // Total responses sent.
+	msgsIn    uint64            // Total messages posted to the room.
+	msgsOut   uint64            // Total messages delivered to members of the room.
+	queue     int               // Requests waiting to be handled by the room.
+	broadcast *metricsHistogram // Latency of broadcasts to the members of the room.
+}
+
+// metricsWriter writes metrics in the Prometheus text exposition format.
+type metricsWriter struct {
+	w io.Writer
+}
+
+// family writes the help and type of a metric. The samples of the metric must follow.
+func (m *metricsWriter) family(name string, typ string, help string) {
+	fmt.Fprintf(m.w, "# HELP %s%s %s\n# TYPE %s%s %s\n", metricsPrefix, name, help, metricsPrefix, name, typ)
+}
+
+// sample writes one value of a metric. Labels are given as name and value pairs.
+func (m *metricsWriter) sample(name string, v float64, labels ...string) {
+	fmt.Fprintf(m.w, "%s%s%s %s\n", metricsPrefix, name, metricsLabels(labels), metricsValue(v))
+}
+
+// histogram writes the buckets, sum and count of a histogram.
+func (m *metricsWriter) histogram(name string, h *metricsHistogram, labels ...string) {
+	var cum uint64
+	for i, b := range h.bounds {
+		cum += h.counts[i]
+		m.sample(name+"_bucket", float64(cum), append(labels, "le", metricsValue(b))...)
+	}
+	m.sample(name+"_bucket", float64(h.count), append(labels, "le", "+Inf")...)
+	m.sample(name+"_sum", h.sum, labels...)
+	m.sample(name+"_count", float64(h.count), labels...)
+}
+
+// metricsLabels formats name and value pairs as a label set.
+func metricsLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	var pairs []string
+	for i := 0; i+1 < len(labels); i += 2 {
+		pairs = append(pairs, fmt.Sprintf(`%s="%s"`, labels[i], metricsEscape(labels[i+1])))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// metricsEscape escapes a label value.
+func metricsEscape(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+// metricsValue formats a sample value.
+func metricsValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// writeMetrics writes the metrics of the server, its chatters and rooms.
+func (s *Server) writeMetrics(w io.Writer) {
+	s.mu.RLock()
+	start := s.stats.Start
+	reqs, reqBytes := s.stats.ReqCount, s.stats.ReqBytes
+	var routes []string
+	routeReqs := make(map[string]int64)
+	routeBytes := make(map[string]int64)
+	for r, st := range s.stats.RouteStats {
+		routes = append(routes, r)
+		routeReqs[r] = st["requestCount"]
+		routeBytes[r] = st["requestBytes"]
+	}
+	s.mu.RUnlock()
+	sort.Strings(routes)
+	chatters, chatterReqs, chatterRsps, queued := s.cMngr.chatterMetrics()
+	rooms := s.cMngr.roomMetrics()
+
+	m := &metricsWriter{w}
+	m.family("start_time_seconds", "gauge", "Start time of the server since the unix epoch in seconds.")
+	m.sample("start_time_seconds", float64(start.Unix()))
+	m.family("requests_total", "counter", "Requests received by the server.")
+	m.sample("requests_total", float64(reqs))
+	m.family("request_bytes_total", "counter", "Size of the requests received by the server in bytes.")
+	m.sample("request_bytes_total", float64(reqBytes))
+	m.family("route_requests_total", "counter", "Requests received by each route.")
+	for _, r := range routes {
+		m.sample("route_requests_total", float64(routeReqs[r]), "route", r)
+	}
+	m.family("route_request_bytes_total", "counter", "Size of the requests received by each route in bytes.")
+	for _, r := range routes {
+		m.sample("route_request_bytes_total", float64(routeBytes[r]), "route", r)
+	}
+
+	m.family("connections", "gauge", "Chatters connected to the server.")
+	m.sample("connections", float64(chatters))
+	m.family("chatter_requests", "gauge", "Requests received from the connected chatters.")
+	m.sample("chatter_requests", float64(chatterReqs))
+	m.family("chatter_responses", "gauge", "Responses sent to the connected chatters.")
+	m.sample("chatter_responses", float64(chatterRsps))
+	m.family("chatter_queue_depth", "gauge", "Responses waiting to be sent to the connected chatters.")
+	m.sample("chatter_queue_depth", float64(queued))
+
+	throttled, kicks := s.cMngr.throttleStats()
+	m.family("throttled_total", "counter", "Chat requests dropped by rate limits.")
+	m.sample("throttled_total", float64(throttled))
+	m.family("flood_disconnects_total", "counter", "Chatters disconnected for exceeding rate limits.")
+	m.sample("flood_disconnects_total", float64(kicks))
+
+	m.family("rooms", "gauge", "Chat rooms on the server.")
+	m.sample("rooms", float64(len(rooms)))
+	m.family("room_members", "gauge", "Chatters in each room.")
+	for _, r := range rooms {
+		m.sample("room_members", float64(r.members), "room", r.name)
+	}
+	m.family("room_requests_total", "counter", "Requests received by each room.")
+	for _, r := range rooms {
+		m.sample("room_requests_total", float64(r.reqCount), "room", r.name)
+	}
+	m.family("room_responses_total", "counter", "Responses sent by each room.")
+	for _, r := range rooms {
+		m.sample("room_responses_total", float64(r.rspCount), "room", r.name)
+	}
+	m.family("room_messages_in_total", "counter", "Messages posted to each room.")
+	for _, r := range rooms {
+		m.sample("room_messages_in_total", float64(r.msgsIn), "room", r.name)
+	}
+	m.family("room_messages_out_total", "counter", "Messages delivered to the members of each room.")
+	for _, r := range rooms {
+		m.sample("room_messages_out_total", float64(r.msgsOut), "room", r.name)
+	}
+	m.family("room_queue_depth", "gauge", "Requests waiting to be handled by each room.")
+	for _, r := range rooms {
+		m.sample("room_queue_depth", float64(r.queue), "room", r.name)
+	}
+	m.family("room_broadcast_seconds", "histogram", "Time to queue a broadcast to the members of each room.")
+	for _, r := range rooms {
+		m.histogram("room_broadcast_seconds", r.broadcast, "room", r.name)
+	}
+}
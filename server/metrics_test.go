@@ -0,0 +1,79 @@
+package server
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var (
+	// A comment or sample line of the Prometheus text exposition format.
+	testMetricsLine = regexp.MustCompile(`^(# (HELP|TYPE) chattypantz_\w+ .+|` +
+		`chattypantz_\w+(\{(\w+="([^"\\]|\\.)*",?)+\})? (\+Inf|-Inf|NaN|[-0-9.e+]+))$`)
+)
+
+func TestMetricsHistogram(t *testing.T) {
+	t.Parallel()
+	h := metricsHistogramNew([]float64{.1, 1})
+	h.observe(.05)
+	h.observe(.5)
+	h.observe(.5)
+	h.observe(5)
+	var b bytes.Buffer
+	m := &metricsWriter{&b}
+	m.histogram("latency_seconds", h.snapshot(), "room", "Room1")
+	exp := `chattypantz_latency_seconds_bucket{room="Room1",le="0.1"} 1
+chattypantz_latency_seconds_bucket{room="Room1",le="1"} 3
+chattypantz_latency_seconds_bucket{room="Room1",le="+Inf"} 4
+chattypantz_latency_seconds_sum{room="Room1"} 6.05
+chattypantz_latency_seconds_count{room="Room1"} 4
+`
+	if b.String() != exp {
+		t.Errorf("Histogram output incorrect.\nExpected: %s\n\nActual: %s\n", exp, b.String())
+	}
+}
+
+func TestMetricsLabels(t *testing.T) {
+	t.Parallel()
+	if l := metricsLabels(nil); l != "" {
+		t.Errorf("No labels should be blank. Actual: %s", l)
+	}
+	l := metricsLabels([]string{"room", "A \"quoted\"\\room\n"})
+	if exp := `{room="A \"quoted\"\\room\n"}`; l != exp {
+		t.Errorf("Labels incorrect.\nExpected: %s\n\nActual: %s\n", exp, l)
+	}
+}
+
+func TestMetricsWrite(t *testing.T) {
+	t.Parallel()
+	s := tTestAdminServer("")
+	defer s.cMngr.shutdownAll()
+	s.stats.IncrReqStats(10)
+	s.stats.IncrRouteStats(httpRouteV1Stats, 10)
+	r, _ := s.cMngr.createRoom(`Room "1"`)
+	r.sendResponseAll(ChatRspTypeMsg, "Hello", nil)
+
+	var b bytes.Buffer
+	s.writeMetrics(&b)
+	out := b.String()
+	for _, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if !testMetricsLine.MatchString(line) {
+			t.Errorf("Metrics line is not in the exposition format: %s", line)
+		}
+	}
+	for _, exp := range []string{
+		"# TYPE chattypantz_requests_total counter\nchattypantz_requests_total 1\n",
+		`chattypantz_route_requests_total{route="/v1.0/stats"} 1`,
+		"# TYPE chattypantz_connections gauge\nchattypantz_connections 0\n",
+		"chattypantz_rooms 1\n",
+		`chattypantz_room_members{room="Room \"1\""} 0`,
+		`chattypantz_room_queue_depth{room="Room \"1\""} 0`,
+		"# TYPE chattypantz_room_broadcast_seconds histogram\n",
+		`chattypantz_room_broadcast_seconds_count{room="Room \"1\""} 1`,
+	} {
+		if !strings.Contains(out, exp) {
+			t.Errorf("Metrics should contain: %s\n\nActual: %s\n", exp, out)
+		}
+	}
+}
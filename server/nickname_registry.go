@@ -0,0 +1,139 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+const maxNicknamePassword = 72 // The longest password bcrypt can hash, in bytes.
+
+var (
+	nicknameRegistryErrRegistered      = errors.New("nickname is already registered")
+	nicknameRegistryErrNotRegistered   = errors.New("nickname is not registered")
+	nicknameRegistryErrWrongPassword   = errors.New("wrong password for nickname")
+	nicknameRegistryErrPasswordTooLong = errors.New("password is too long")
+)
+
+// nicknameEntry is a single registered nickname as it is stored in the registry file.
+type nicknameEntry struct {
+	Hash       string    `json:"hash"`       // The bcrypt hash of the password.
+	Registered time.Time `json:"registered"` // The time the nickname was registered (UTC).
+}
+
+// NicknameRegistry represents the server wide list of nicknames claimed with a password.
+type NicknameRegistry struct {
+	mu    sync.RWMutex              // Lock for update.
+	path  string                    // The file the registry is persisted to, or blank for none.
+	nicks map[string]*nicknameEntry // The registered nicknames.
+}
+
+// NicknameRegistryNew is a factory function that returns a new nickname registry. Any
+// registrations previously saved to the file are loaded. A blank path keeps the registry in memory.
+func NicknameRegistryNew(path string) (*NicknameRegistry, error) {
+	r := &NicknameRegistry{
+		path:  path,
+		nicks: make(map[string]*nicknameEntry),
+	}
+	if path == "" {
+		return r, nil
+	}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return r, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &r.nicks); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// isRegistered validates whether a nickname has been registered.
+func (r *NicknameRegistry) isRegistered(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	_, ok := r.nicks[name]
+	return ok
+}
+
+// register claims a nickname with a password and saves the registry. The password is hashed
+// before the registry is locked as bcrypt is slow on purpose.
+func (r *NicknameRegistry) register(name string, pwd string) error {
+	if len(pwd) > maxNicknamePassword {
+		return nicknameRegistryErrPasswordTooLong
+	}
+	if r.isRegistered(name) {
+		return nicknameRegistryErrRegistered
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pwd), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.nicks[name]; ok {
+		return nicknameRegistryErrRegistered
+	}
+	r.nicks[name] = &nicknameEntry{
+		Hash:       string(hash),
+		Registered: time.Now().UTC(),
+	}
+	return r.save()
+}
+
+// identify validates the password of a registered nickname.
+func (r *NicknameRegistry) identify(name string, pwd string) error {
+	r.mu.RLock()
+	e, ok := r.nicks[name]
+	r.mu.RUnlock()
+	if !ok {
+		return nicknameRegistryErrNotRegistered
+	}
+	if bcrypt.CompareHashAndPassword([]byte(e.Hash), []byte(pwd)) != nil {
+		return nicknameRegistryErrWrongPassword
+	}
+	return nil
+}
+
+// drop releases a registered nickname once the password is validated and saves the registry.
+func (r *NicknameRegistry) drop(name string, pwd string) error {
+	if err := r.identify(name, pwd); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.nicks, name)
+	return r.save()
+}
+
+// save writes the registry to its file. The file is replaced in one step so a crash cannot
+// leave it half written.
+func (r *NicknameRegistry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	b, _ := json.MarshalIndent(r.nicks, "", "  ")
+	tmp, err := ioutil.TempFile(filepath.Dir(r.path), filepath.Base(r.path))
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), r.path)
+}
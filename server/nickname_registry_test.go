@@ -0,0 +1,76 @@
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestNicknameRegistry(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "chattypantz")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "nicknames.json")
+
+	r, err := NicknameRegistryNew(path)
+	if err != nil {
+		t.Fatalf("Registry should have been created. Err: %s", err)
+	}
+	if err := r.register("ChatMonkey", "secret"); err != nil {
+		t.Errorf("Nickname should have been registered. Err: %s", err)
+	}
+	if err := r.register("ChatMonkey", "other"); err != nicknameRegistryErrRegistered {
+		t.Errorf("Nickname should already be registered. Err: %v", err)
+	}
+	if err := r.register("MonkeyTester", strings.Repeat("x", maxNicknamePassword+1)); err !=
+		nicknameRegistryErrPasswordTooLong {
+		t.Errorf("Password longer than bcrypt can hash should be refused. Err: %v", err)
+	}
+	if b, _ := ioutil.ReadFile(path); !strings.Contains(string(b), `"hash": "$2`) || strings.Contains(string(b), "secret") {
+		t.Errorf("Password should be saved as a bcrypt hash. Actual: %s", b)
+	}
+
+	// A restart should pick the registrations back up.
+	r, err = NicknameRegistryNew(path)
+	if err != nil {
+		t.Fatalf("Registry should have been reloaded. Err: %s", err)
+	}
+	if !r.isRegistered("ChatMonkey") {
+		t.Errorf("Nickname should be registered after reload.")
+	}
+	if err := r.identify("ChatMonkey", "wrong"); err != nicknameRegistryErrWrongPassword {
+		t.Errorf("Identify should fail with the wrong password. Err: %v", err)
+	}
+	if err := r.identify("MonkeyTester", "secret"); err != nicknameRegistryErrNotRegistered {
+		t.Errorf("Identify should fail for an unknown nickname. Err: %v", err)
+	}
+	if err := r.identify("ChatMonkey", "secret"); err != nil {
+		t.Errorf("Identify should succeed. Err: %s", err)
+	}
+	if err := r.drop("ChatMonkey", "wrong"); err != nicknameRegistryErrWrongPassword {
+		t.Errorf("Drop should fail with the wrong password. Err: %v", err)
+	}
+	if err := r.drop("ChatMonkey", "secret"); err != nil {
+		t.Errorf("Drop should succeed. Err: %s", err)
+	}
+	r, _ = NicknameRegistryNew(path)
+	if r.isRegistered("ChatMonkey") {
+		t.Errorf("Nickname should not be registered after drop.")
+	}
+}
+
+func TestNicknameRegistryMemory(t *testing.T) {
+	t.Parallel()
+	r, err := NicknameRegistryNew("")
+	if err != nil {
+		t.Fatalf("Registry should have been created. Err: %s", err)
+	}
+	if err := r.register("ChatMonkey", "secret"); err != nil || !r.isRegistered("ChatMonkey") {
+		t.Errorf("Nickname should be registered in memory. Err: %v", err)
+	}
+}
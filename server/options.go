@@ -5,15 +5,47 @@ import "encoding/json"
 // Options represents parameters that are passed to the application to be used in constructing
 // the server.
 type Options struct {
-	Name     string `json:"name"`         // The name of the server.
-	Hostname string `json:"hostname"`     // The hostname of the server.
-	Port     int    `json:"port"`         // The default port of the server.
-	ProfPort int    `json:"profPort"`     // The profiler port of the server.
-	MaxConns int    `json:"maxConns"`     // The maximum concurrent clients accepted.
-	MaxRooms int    `json:"maxRooms"`     // The maximum number of chat rooms allowed.
-	MaxIdle  int    `json:"maxIdle"`      // The maximum client idle time in seconds before disconnect.
-	MaxProcs int    `json:"maxProcs"`     // The maximum number of processor cores available.
-	Debug    bool   `json:"debugEnabled"` // Is debugging enabled in the application or server.
+	Name        string   `json:"name"`                  // The name of the server.
+	Hostname    string   `json:"hostname"`              // The hostname of the server.
+	Port        int      `json:"port"`                  // The default port of the server.
+	ProfPort    int      `json:"profPort"`              // The profiler port of the server.
+	MaxConns    int      `json:"maxConns"`              // The maximum concurrent clients accepted.
+	MaxRooms    int      `json:"maxRooms"`              // The maximum number of chat rooms allowed.
+	MaxIdle     int      `json:"maxIdle"`               // The maximum client idle time in seconds before disconnect.
+	MaxProcs    int      `json:"maxProcs"`              // The maximum number of processor cores available.
+	MaxHist     int      `json:"maxHistory"`            // The maximum number of messages replayed on join.
+	HistDir     string   `json:"historyDir"`            // The directory where room history is stored.
+	Admins      []string `json:"admins"`                // Nicknames allowed to administer any room.
+	AuthSecret  string   `json:"-" config:"authSecret"` // Shared secret to verify chat authentication tokens.
+	NickFile    string   `json:"nicknameFile"`          // The file where registered nicknames are stored.
+	UniqueNick  bool     `json:"uniqueNicks"`           // Must nicknames be unique across the server?
+	TLSCert     string   `json:"tlsCert"`               // The certificate file to serve TLS with.
+	TLSKey      string   `json:"tlsKey"`                // The private key file to serve TLS with.
+	TLSCA       string   `json:"tlsClientCA"`           // The CA file client certificates are verified against.
+	Bans        []string `json:"bans"`                  // Nicknames and IPs banned from the server.
+	MOTD        string   `json:"motd"`                  // The message of the day sent to chatters on connect.
+	Grace       int      `json:"shutdownGrace"`         // Seconds allowed on shutdown for queues to drain.
+	Reconnect   int      `json:"reconnectDelay"`        // Seconds chatters are asked to wait to reconnect.
+	MsgRate     int      `json:"msgRate"`               // The messages per second allowed to each chatter.
+	MsgBurst    int      `json:"msgBurst"`              // The messages allowed at once to each chatter.
+	JoinRate    int      `json:"joinRate"`              // The room joins per minute allowed to each chatter.
+	MaxThrottle int      `json:"maxThrottles"`          // The throttled requests per minute before a disconnect.
+	MaxFrame    int      `json:"maxFrameBytes"`         // The maximum bytes in a request frame.
+	MaxMsgLen   int      `json:"maxMessageLength"`      // The maximum characters in a message.
+	MaxNickLen  int      `json:"maxNicknameLength"`     // The maximum characters in a nickname.
+	MaxRoomLen  int      `json:"maxRoomNameLength"`     // The maximum characters in a room name.
+	WordFile    string   `json:"wordFilterFile"`        // A file of words masked in messages.
+	StripLinks  bool     `json:"stripLinks"`            // Are links removed from messages?
+	Blocks      []string `json:"blockPatterns"`         // Regular expressions of messages that are refused.
+	TypingTTL   int      `json:"typingTimeout"`         // Seconds a typing notice lasts without a stop.
+	TypingIdle  bool     `json:"typingResetsIdle"`      // Do typing notices reset the idle timeout?
+	AwayIdle    int      `json:"awayIdle"`              // Seconds idle before a chatter is shown as away.
+	PingPeriod  int      `json:"pingInterval"`          // Seconds between pings to each chatter.
+	PongWait    int      `json:"pongTimeout"`           // Seconds a chatter has to answer a ping.
+	WriteWait   int      `json:"writeTimeout"`          // Seconds a send to a chatter may block.
+	AdminToken  string   `json:"-" config:"adminToken"` // Token to authorize admin API requests.
+	Debug       bool     `json:"debugEnabled"`          // Is debugging enabled in the application or server.
+	Config      string   `json:"configFile" config:"-"` // The configuration file the options were loaded from.
 }
 
 // String is an implentation of the Stringer interface so the structure is returned as a string
@@ -0,0 +1,283 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	optionsEnvPrefix = "CHATTYPANTZ_" // The prefix of environment variables that override options.
+)
+
+var (
+	// The keys of options that only take effect when the server is restarted.
+	optionsRestartKeys = map[string]bool{
+		"hostname":     true,
+		"port":         true,
+		"profPort":     true,
+		"maxConns":     true,
+		"maxProcs":     true,
+		"maxHistory":   true,
+		"historyDir":   true,
+		"nicknameFile": true,
+		"tlsCert":      true,
+		"tlsKey":       true,
+		"tlsClientCA":  true,
+	}
+)
+
+// optionsChange is a single option that differs between two sets of options.
+type optionsChange struct {
+	key     string // The configuration key of the option.
+	from    string // The old value.
+	to      string // The new value.
+	restart bool   // Does the change only take effect on restart?
+}
+
+// String is an implentation of the Stringer interface so the structure is returned as a
+// string to fmt.Print() etc. Secrets are never shown.
+func (c *optionsChange) String() string {
+	if c.key == "authSecret" || c.key == "adminToken" {
+		return fmt.Sprintf(`"%s" changed`, c.key)
+	}
+	return fmt.Sprintf(`"%s" changed from %s to %s`, c.key, c.from, c.to)
+}
+
+// optionsDiff returns the configurable options that differ between the old and new options.
+func optionsDiff(old *Options, cur *Options) []*optionsChange {
+	var changes []*optionsChange
+	t := reflect.TypeOf(*old)
+	for i := 0; i < t.NumField(); i++ {
+		key := optionsKey(t.Field(i))
+		fa := reflect.ValueOf(old).Elem().Field(i)
+		fb := reflect.ValueOf(cur).Elem().Field(i)
+		if key == "-" || reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			continue
+		}
+		if fa.Kind() == reflect.Slice && fa.Len() == 0 && fb.Len() == 0 { // nil or empty.
+			continue
+		}
+		a, _ := json.Marshal(fa.Interface())
+		b, _ := json.Marshal(fb.Interface())
+		changes = append(changes, &optionsChange{
+			key:     key,
+			from:    string(a),
+			to:      string(b),
+			restart: optionsRestartKeys[key],
+		})
+	}
+	return changes
+}
+
+// OptionsError is returned when an option cannot be loaded or is not valid. Key is the option key
+// as used in a configuration file, or the name of the environment variable it was read from.
+type OptionsError struct {
+	Key    string // The offending key.
+	Reason string // Why the value was refused.
+}
+
+// Error is an implementation of the error interface.
+func (e *OptionsError) Error() string {
+	return fmt.Sprintf(`option "%s" %s`, e.Key, e.Reason)
+}
+
+// Load reads a JSON or YAML configuration file, if a path is given, followed by any CHATTYPANTZ_*
+// environment variables into the options. Options that are not set keep their current value.
+// Environment variables are named after the configuration key, ex: maxConns is
+// CHATTYPANTZ_MAX_CONNS.
+func (o *Options) Load(path string) error {
+	if path != "" {
+		if err := o.loadFile(path); err != nil {
+			return err
+		}
+		o.Config = path
+	}
+	return o.loadEnv(os.LookupEnv)
+}
+
+// loadFile reads a configuration file. Files ending in .yaml or .yml are read as YAML, anything
+// else as JSON.
+func (o *Options) loadFile(path string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	m := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &m)
+	default:
+		err = json.Unmarshal(b, &m)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot parse configuration file %s: %s", path, err.Error())
+	}
+	for k, v := range m {
+		f, ok := o.field(k)
+		if !ok {
+			return &OptionsError{k, "is not a known option"}
+		}
+		b, err := json.Marshal(v)
+		if err == nil {
+			err = json.Unmarshal(b, f.Addr().Interface())
+		}
+		if err != nil {
+			return &OptionsError{k, "must be " + optionsKind(f)}
+		}
+	}
+	return nil
+}
+
+// loadEnv reads the environment variables named after each configuration key.
+func (o *Options) loadEnv(lookup func(string) (string, bool)) error {
+	t := reflect.TypeOf(*o)
+	for i := 0; i < t.NumField(); i++ {
+		key := optionsKey(t.Field(i))
+		if key == "-" {
+			continue
+		}
+		name := optionsEnvName(key)
+		v, ok := lookup(name)
+		if !ok {
+			continue
+		}
+		f := reflect.ValueOf(o).Elem().Field(i)
+		switch f.Kind() {
+		case reflect.String:
+			f.SetString(v)
+		case reflect.Int:
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return &OptionsError{name, "must be " + optionsKind(f)}
+			}
+			f.SetInt(int64(n))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return &OptionsError{name, "must be " + optionsKind(f)}
+			}
+			f.SetBool(b)
+		case reflect.Slice:
+			f.Set(reflect.ValueOf(strings.Split(v, ",")))
+		}
+	}
+	return nil
+}
+
+// Validate checks the options are usable to run a server.
+func (o *Options) Validate() error {
+	switch {
+	case o.Hostname == "":
+		return &OptionsError{"hostname", "must not be empty"}
+	case o.Port < 1 || o.Port > 65535:
+		return &OptionsError{"port", "must be between 1 and 65535"}
+	case o.ProfPort > 65535:
+		return &OptionsError{"profPort", "must not be greater than 65535"}
+	case o.MaxHist > 0 && o.HistDir == "":
+		return &OptionsError{"historyDir", "must not be empty when maxHistory is set"}
+	case o.TLSCert != "" && o.TLSKey == "":
+		return &OptionsError{"tlsKey", "must be set with tlsCert"}
+	case o.TLSKey != "" && o.TLSCert == "":
+		return &OptionsError{"tlsCert", "must be set with tlsKey"}
+	case o.TLSCA != "" && o.TLSCert == "":
+		return &OptionsError{"tlsClientCA", "requires tlsCert and tlsKey"}
+	case o.MsgRate < 0:
+		return &OptionsError{"msgRate", "must not be negative"}
+	case o.MsgBurst < 0:
+		return &OptionsError{"msgBurst", "must not be negative"}
+	case o.JoinRate < 0:
+		return &OptionsError{"joinRate", "must not be negative"}
+	case o.MaxThrottle < 0:
+		return &OptionsError{"maxThrottles", "must not be negative"}
+	case o.MaxFrame < 0:
+		return &OptionsError{"maxFrameBytes", "must not be negative"}
+	case o.MaxMsgLen < 0:
+		return &OptionsError{"maxMessageLength", "must not be negative"}
+	case o.MaxNickLen < 0:
+		return &OptionsError{"maxNicknameLength", "must not be negative"}
+	case o.MaxRoomLen < 0:
+		return &OptionsError{"maxRoomNameLength", "must not be negative"}
+	case o.Grace < 0:
+		return &OptionsError{"shutdownGrace", "must not be negative"}
+	case o.Reconnect < 0:
+		return &OptionsError{"reconnectDelay", "must not be negative"}
+	case o.TypingTTL < 0:
+		return &OptionsError{"typingTimeout", "must not be negative"}
+	case o.AwayIdle < 0:
+		return &OptionsError{"awayIdle", "must not be negative"}
+	case o.AwayIdle > 0 && o.MaxIdle > 0 && o.AwayIdle >= o.MaxIdle:
+		return &OptionsError{"awayIdle", "must be less than maxIdle"}
+	case o.PingPeriod < 0:
+		return &OptionsError{"pingInterval", "must not be negative"}
+	case o.PongWait < 0:
+		return &OptionsError{"pongTimeout", "must not be negative"}
+	case o.WriteWait < 0:
+		return &OptionsError{"writeTimeout", "must not be negative"}
+	}
+	if _, err := RegexFilterNew(o.Blocks); err != nil {
+		return &OptionsError{"blockPatterns", "must be valid regular expressions: " + err.Error()}
+	}
+	for _, a := range o.Admins {
+		if a == "" {
+			return &OptionsError{"admins", "must not contain an empty nickname"}
+		}
+	}
+	return nil
+}
+
+// field returns the option for a configuration key.
+func (o *Options) field(key string) (reflect.Value, bool) {
+	t := reflect.TypeOf(*o)
+	for i := 0; i < t.NumField(); i++ {
+		if k := optionsKey(t.Field(i)); k != "-" && k == key {
+			return reflect.ValueOf(o).Elem().Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+// optionsKey returns the configuration key of an option. This is the json name unless a config
+// tag says otherwise. A key of "-" cannot be configured.
+func optionsKey(f reflect.StructField) string {
+	if k := f.Tag.Get("config"); k != "" {
+		return k
+	}
+	return strings.Split(f.Tag.Get("json"), ",")[0]
+}
+
+// optionsEnvName returns the environment variable for a configuration key.
+func optionsEnvName(key string) string {
+	var b []rune
+	prev := ' '
+	for _, r := range key {
+		if unicode.IsUpper(r) && unicode.IsLower(prev) {
+			b = append(b, '_')
+		}
+		b = append(b, unicode.ToUpper(r))
+		prev = r
+	}
+	return optionsEnvPrefix + string(b)
+}
+
+// optionsKind describes the type of value an option accepts.
+func optionsKind(f reflect.Value) string {
+	switch f.Kind() {
+	case reflect.Int:
+		return "an integer"
+	case reflect.Bool:
+		return "true or false"
+	case reflect.Slice:
+		return "a list of strings"
+	default:
+		return "a string"
+	}
+}
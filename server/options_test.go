@@ -2,26 +2,69 @@ package server
 
 import (
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 )
 
 const (
 	testOptionsExpectedJSONResult = `{"name":"Test Options","hostname":"0.0.0.0","port":6661,` +
-		`"profPort":6061,"maxConns":1001,"maxRooms":999,"maxIdle":888,"maxProcs":777,"debugEnabled":true}`
+		`"profPort":6061,"maxConns":1001,"maxRooms":999,"maxIdle":888,"maxProcs":777,"maxHistory":666,` +
+		`"historyDir":"/tmp/history","admins":["ChatMonkey"],"nicknameFile":"/tmp/nicknames.json",` +
+		`"uniqueNicks":true,"tlsCert":"/tmp/cert.pem","tlsKey":"/tmp/key.pem","tlsClientCA":"/tmp/ca.pem",` +
+		`"bans":["10.0.0.1"],"motd":"Be nice.","shutdownGrace":5,"reconnectDelay":10,"msgRate":5,` +
+		`"msgBurst":10,"joinRate":20,"maxThrottles":3,` +
+		`"maxFrameBytes":1024,"maxMessageLength":512,"maxNicknameLength":16,"maxRoomNameLength":24,` +
+		`"wordFilterFile":"/tmp/words.txt","stripLinks":true,"blockPatterns":["(?i)spam"],` +
+		`"typingTimeout":5,"typingResetsIdle":true,"awayIdle":300,` +
+		`"pingInterval":30,"pongTimeout":10,"writeTimeout":10,` +
+		`"debugEnabled":true,` +
+		`"configFile":"/tmp/chattypantz.yaml"}`
 )
 
 func TestOptionsString(t *testing.T) {
 	t.Parallel()
 	opts := &Options{
-		Name:     "Test Options",
-		Hostname: "0.0.0.0",
-		Port:     6661,
-		ProfPort: 6061,
-		MaxConns: 1001,
-		MaxRooms: 999,
-		MaxIdle:  888,
-		MaxProcs: 777,
-		Debug:    true,
+		Name:        "Test Options",
+		Hostname:    "0.0.0.0",
+		Port:        6661,
+		ProfPort:    6061,
+		MaxConns:    1001,
+		MaxRooms:    999,
+		MaxIdle:     888,
+		MaxProcs:    777,
+		MaxHist:     666,
+		HistDir:     "/tmp/history",
+		Admins:      []string{"ChatMonkey"},
+		NickFile:    "/tmp/nicknames.json",
+		UniqueNick:  true,
+		TLSCert:     "/tmp/cert.pem",
+		TLSKey:      "/tmp/key.pem",
+		TLSCA:       "/tmp/ca.pem",
+		Bans:        []string{"10.0.0.1"},
+		MOTD:        "Be nice.",
+		MsgRate:     5,
+		MsgBurst:    10,
+		JoinRate:    20,
+		MaxThrottle: 3,
+		MaxFrame:    1024,
+		MaxMsgLen:   512,
+		MaxNickLen:  16,
+		MaxRoomLen:  24,
+		WordFile:    "/tmp/words.txt",
+		StripLinks:  true,
+		Blocks:      []string{"(?i)spam"},
+		TypingTTL:   5,
+		TypingIdle:  true,
+		AwayIdle:    300,
+		PingPeriod:  30,
+		PongWait:    10,
+		WriteWait:   10,
+		Grace:       5,
+		Reconnect:   10,
+		Debug:       true,
+		Config:      "/tmp/chattypantz.yaml",
 	}
 	actual := fmt.Sprint(opts)
 	if actual != testOptionsExpectedJSONResult {
@@ -29,3 +72,116 @@ func TestOptionsString(t *testing.T) {
 			testOptionsExpectedJSONResult, actual)
 	}
 }
+
+func TestOptionsLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chattypantz")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	yml := filepath.Join(dir, "chattypantz.yaml")
+	ioutil.WriteFile(yml, []byte("name: San Francisco\nport: 6661\nadmins: [ChatMonkey]\nauthSecret: Shhhhh\n"), 0600)
+	js := filepath.Join(dir, "chattypantz.json")
+	ioutil.WriteFile(js, []byte(`{"maxRooms":50,"uniqueNicks":true}`), 0600)
+
+	os.Setenv("CHATTYPANTZ_PORT", "6662")
+	os.Setenv("CHATTYPANTZ_ADMINS", "ChatMonkey,MonkeyTester")
+	defer os.Unsetenv("CHATTYPANTZ_PORT")
+	defer os.Unsetenv("CHATTYPANTZ_ADMINS")
+
+	opts := &Options{Hostname: DefaultHostname, Port: DefaultPort, MaxIdle: 30}
+	if err := opts.Load(yml); err != nil {
+		t.Fatalf("YAML options should have loaded. Err: %s", err)
+	}
+	switch {
+	case opts.Name != "San Francisco", opts.AuthSecret != "Shhhhh", opts.Config != yml:
+		t.Errorf("YAML options not loaded. Actual: %s", opts)
+	case opts.Port != 6662, len(opts.Admins) != 2:
+		t.Errorf("Environment should override the file. Actual: %s", opts)
+	case opts.MaxIdle != 30:
+		t.Errorf("Options not in the file should be kept. Actual: %s", opts)
+	}
+	if err := opts.Load(js); err != nil {
+		t.Fatalf("JSON options should have loaded. Err: %s", err)
+	}
+	if opts.MaxRooms != 50 || !opts.UniqueNick {
+		t.Errorf("JSON options not loaded. Actual: %s", opts)
+	}
+
+	tests := []struct {
+		desc string
+		file string
+		env  string
+		key  string
+	}{
+		{"unknown key", `{"maxRoom":50}`, "", "maxRoom"},
+		{"wrong type", `{"port":"high"}`, "", "port"},
+		{"config key", `{"configFile":"other.json"}`, "", "configFile"},
+		{"env type", `{}`, "CHATTYPANTZ_MAX_IDLE", "CHATTYPANTZ_MAX_IDLE"},
+	}
+	for _, tc := range tests {
+		ioutil.WriteFile(js, []byte(tc.file), 0600)
+		if tc.env != "" {
+			os.Setenv(tc.env, "forever")
+		}
+		err := (&Options{}).Load(js)
+		if tc.env != "" {
+			os.Unsetenv(tc.env)
+		}
+		if e, ok := err.(*OptionsError); !ok || e.Key != tc.key {
+			t.Errorf("Load %s should name the key %s. Err: %v", tc.desc, tc.key, err)
+		}
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		opts *Options
+		key  string
+	}{
+		{&Options{Hostname: "localhost", Port: 6660}, ""},
+		{&Options{Port: 6660}, "hostname"},
+		{&Options{Hostname: "localhost", Port: 0}, "port"},
+		{&Options{Hostname: "localhost", Port: 6660, ProfPort: 70000}, "profPort"},
+		{&Options{Hostname: "localhost", Port: 6660, MaxHist: 10}, "historyDir"},
+		{&Options{Hostname: "localhost", Port: 6660, TLSCert: "cert.pem"}, "tlsKey"},
+		{&Options{Hostname: "localhost", Port: 6660, TLSKey: "key.pem"}, "tlsCert"},
+		{&Options{Hostname: "localhost", Port: 6660, TLSCA: "ca.pem"}, "tlsClientCA"},
+		{&Options{Hostname: "localhost", Port: 6660, Admins: []string{""}}, "admins"},
+		{&Options{Hostname: "localhost", Port: 6660, MaxIdle: 600, AwayIdle: 300}, ""},
+		{&Options{Hostname: "localhost", Port: 6660, MaxIdle: 300, AwayIdle: 300}, "awayIdle"},
+		{&Options{Hostname: "localhost", Port: 6660, PongWait: -1}, "pongTimeout"},
+	}
+	for _, tc := range tests {
+		err := tc.opts.Validate()
+		if tc.key == "" {
+			if err != nil {
+				t.Errorf("Options should be valid. Err: %s", err)
+			}
+			continue
+		}
+		if e, ok := err.(*OptionsError); !ok || e.Key != tc.key {
+			t.Errorf("Validate should name the key %s. Err: %v", tc.key, err)
+		}
+	}
+}
+
+func TestOptionsDiff(t *testing.T) {
+	t.Parallel()
+	old := &Options{Port: 6660, MaxRooms: 2, AuthSecret: "Shhhhh", Admins: []string{}, Config: "a.json"}
+	cur := &Options{Port: 6661, MaxRooms: 5, AuthSecret: "Psst", Config: "b.json"}
+	changes := optionsDiff(old, cur)
+	if len(changes) != 3 {
+		t.Fatalf("Expected 3 changes. Actual: %v", changes)
+	}
+	expected := []string{`"port" changed from 6660 to 6661`, `"maxRooms" changed from 2 to 5`, `"authSecret" changed`}
+	for i, c := range changes {
+		if c.String() != expected[i] {
+			t.Errorf("Change incorrect.\nExpected: %s\n\nActual: %s\n", expected[i], c)
+		}
+		if c.restart != (c.key == "port") {
+			t.Errorf("Change %s restart flag incorrect.", c.key)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package server
+
+import "time"
+
+// rateLimiter is a token bucket. Tokens are added at a steady rate up to the burst size and each
+// allowed request takes one.
+type rateLimiter struct {
+	rate   float64   // Tokens added per second.
+	burst  float64   // The most tokens the bucket can hold.
+	tokens float64   // Tokens currently in the bucket.
+	last   time.Time // The last time tokens were added.
+}
+
+// rateLimiterNew is a factory function that returns a full token bucket. A rate of zero or less
+// returns nil, which allows everything. A burst of less than one allows one request at a time.
+func rateLimiterNew(rate float64, burst int, now time.Time) *rateLimiter {
+	if rate <= 0 {
+		return nil
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return &rateLimiter{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   now,
+	}
+}
+
+// allow takes a token from the bucket if one is available.
+func (l *rateLimiter) allow(now time.Time) bool {
+	if l == nil {
+		return true
+	}
+	if elapsed := now.Sub(l.last).Seconds(); elapsed > 0 {
+		l.tokens += elapsed * l.rate
+		if l.tokens > l.burst {
+			l.tokens = l.burst
+		}
+		l.last = now
+	}
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// matches validates whether the bucket was made with the rate and burst.
+func (l *rateLimiter) matches(rate float64, burst int) bool {
+	if l == nil || rate <= 0 {
+		return l == nil && rate <= 0
+	}
+	if burst < 1 {
+		burst = 1
+	}
+	return l.rate == rate && l.burst == float64(burst)
+}
+
+// rateLimits are the limits applied to each chatter. Zeros are no limit.
+type rateLimits struct {
+	msgRate     int // Messages allowed per second.
+	msgBurst    int // Messages allowed at once before the rate applies.
+	joinRate    int // Room joins allowed per minute.
+	maxThrottle int // Throttled requests allowed in a minute before the chatter is disconnected.
+}
@@ -0,0 +1,39 @@
+package server
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	t.Parallel()
+	if l := rateLimiterNew(0, 5, time.Now()); l != nil || !l.allow(time.Now()) {
+		t.Errorf("A zero rate should allow everything.")
+	}
+	now := time.Now()
+	l := rateLimiterNew(2, 3, now)
+	for i := 0; i < 3; i++ {
+		if !l.allow(now) {
+			t.Errorf("Request %d within the burst should be allowed.", i+1)
+		}
+	}
+	if l.allow(now) {
+		t.Errorf("Request over the burst should not be allowed.")
+	}
+	if !l.allow(now.Add(500*time.Millisecond)) || l.allow(now.Add(500*time.Millisecond)) {
+		t.Errorf("One token should have been added after half a second.")
+	}
+	if !l.allow(now.Add(time.Hour)) {
+		t.Errorf("Tokens should have been added after an hour.")
+	}
+	if l.tokens != 2 {
+		t.Errorf("Tokens should not exceed the burst. Actual: %f", l.tokens+1)
+	}
+	if !l.matches(2, 3) || l.matches(1, 3) || !rateLimiterNew(1, 0, now).matches(1, 1) {
+		t.Errorf("Limiter should match its rate and burst.")
+	}
+	var none *rateLimiter
+	if !none.matches(0, 0) || none.matches(1, 1) {
+		t.Errorf("No limiter should only match a zero rate.")
+	}
+}
@@ -2,6 +2,8 @@
 package server
 
 import (
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -11,6 +13,7 @@ import (
 	"os/signal"
 	"runtime"
 	"sync"
+	"syscall"
 	"time"
 
 	// Allow dynamic profiling.
@@ -23,15 +26,20 @@ import (
 
 // Server is the main structure that represents a server instance.
 type Server struct {
-	mu      sync.RWMutex // For locking access to server attributes.
-	running bool         // Is the server running?
-	info    *Info        // Basic server information used to run the server.
-	opts    *Options     // Original options used to create the server.
-	stats   *Stats       // Server statistics since it started.
-	cMngr   *ChatManager // Manager of chatters and chat rooms.
-	srvr    *http.Server // HTTP server.
-	done    chan bool    // A channel to signal to web socked to close.
-	log     *ChatLogger  // Log instance for recording error and other messages.
+	mu         sync.RWMutex             // For locking access to server attributes.
+	running    bool                     // Is the server running?
+	profiling  bool                     // Has the profiler been started?
+	info       *Info                    // Basic server information used to run the server.
+	opts       *Options                 // Options the server is running with.
+	stats      *Stats                   // Server statistics since it started.
+	cMngr      *ChatManager             // Manager of chatters and chat rooms.
+	srvr       *http.Server             // HTTP server.
+	done       chan bool                // A channel to signal the server has finished draining.
+	log        *ChatLogger              // Log instance for recording error and other messages.
+	secret     []byte                   // Shared secret for chat authentication tokens, if enabled.
+	adminToken []byte                   // Token to authorize admin API requests, if enabled.
+	tls        *tlsReloader             // TLS certificates for the listener, if enabled.
+	loader     func() (*Options, error) // Loads the options again for a reload.
 }
 
 // New is a factory function that returns a new server instance.
@@ -45,6 +53,7 @@ func New(ops *Options) *Server {
 			i.MaxConns = ops.MaxConns
 			i.MaxRooms = ops.MaxRooms
 			i.MaxIdle = ops.MaxIdle
+			i.MaxHist = ops.MaxHist
 			i.Debug = ops.Debug
 		}),
 		opts:    ops,
@@ -53,19 +62,25 @@ func New(ops *Options) *Server {
 		running: false,
 	}
 
-	if s.info.Debug {
-		s.log.SetLogLevel(logger.Debug)
-	}
+	s.loader = s.loadOptions
 
 	// Setup the routes.
-	http.Handle(wsRouteV1Conn, websocket.Handler(s.chatHandler))
+	http.Handle(wsRouteV1Conn, chatConnHandler(websocket.Server{Handler: s.chatHandler, Handshake: s.chatHandshake}))
 	http.HandleFunc(httpRouteV1Alive, s.aliveHandler)
 	http.HandleFunc(httpRouteV1Stats, s.statsHandler)
+	http.HandleFunc(httpRouteV1Admin, s.adminHandler)
+	http.HandleFunc(httpRouteMetrics, s.metricsHandler)
 	s.srvr = &http.Server{
 		Addr: fmt.Sprintf("%s:%d", s.info.Hostname, s.info.Port),
 	}
 
-	s.cMngr = ChatManagerNew(s.info.MaxRooms, s.info.MaxIdle, s.log)
+	s.cMngr = ChatManagerNew(s.info.MaxRooms, s.info.MaxIdle, s.info.MaxHist, ops.HistDir, s.log)
+	if nicks, err := NicknameRegistryNew(ops.NickFile); err != nil {
+		s.log.Errorf("Cannot load nickname registry \"%s\": %s", ops.NickFile, err.Error())
+	} else {
+		s.cMngr.SetNicknames(nicks)
+	}
+	s.applyOptions(ops)
 	s.handleSignals()
 	return s
 }
@@ -94,18 +109,39 @@ func (s *Server) Start() error {
 	if s.info.MaxConns > 0 {
 		ln = netutil.LimitListener(ln, s.info.MaxConns)
 	}
+	// If we have certificates, serve wss:// and https:// over TLS.
+	if s.opts.TLSCert != "" || s.opts.TLSKey != "" {
+		t, err := tlsReloaderNew(s.opts.TLSCert, s.opts.TLSKey, s.opts.TLSCA, s.log)
+		if err != nil {
+			ln.Close()
+			s.log.Errorf("Cannot load TLS certificates: %s", err.Error())
+			return err
+		}
+		s.mu.Lock()
+		s.tls = t
+		s.mu.Unlock()
+		ln = tls.NewListener(ln, t.tlsConfig())
+	}
 
 	s.mu.Lock()
 
-	// Pprof http endpoint for the profiler.
-	if s.info.ProfPort > 0 {
+	// Pprof http endpoint for the profiler. It keeps running across a restart.
+	if s.info.ProfPort > 0 && !s.profiling {
+		s.profiling = true
 		s.StartProfiler()
 	}
 
 	s.stats.Start = time.Now()
 	s.running = true
+	s.srvr = &http.Server{Addr: s.srvr.Addr} // A shut down http.Server cannot serve again.
+	s.done = make(chan bool)
+	srvr, done := s.srvr, s.done
 	s.mu.Unlock()
-	err = s.srvr.Serve(ln)
+	err = srvr.Serve(ln)
+	if err == http.ErrServerClosed { // Wait for Shutdown() to finish draining.
+		<-done
+		err = nil
+	}
 
 	// Done.
 	s.mu.Lock()
@@ -117,6 +153,146 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// AddMessageFilter registers a filter of messages posted by chatters. Filters run in the order
+// they are added, after the built-in filters enabled by the options.
+func (s *Server) AddMessageFilter(f MessageFilter) {
+	s.cMngr.AddMessageFilter(f)
+}
+
+// AddSlashCommand registers a slash command chatters may type into messages, replacing any
+// command using the same name.
+func (s *Server) AddSlashCommand(cmd *SlashCommand) error {
+	return s.cMngr.AddSlashCommand(cmd)
+}
+
+// AddBot connects a bot to the server as a chatter using the nickname. The bot stays connected
+// until it is removed, and is started again if the server is restarted.
+func (s *Server) AddBot(nickname string, b Bot) error {
+	return s.cMngr.addBot(nickname, b)
+}
+
+// RemoveBot disconnects the bot using the nickname.
+func (s *Server) RemoveBot(nickname string) error {
+	return s.cMngr.removeBot(nickname)
+}
+
+// ReloadTLS loads the TLS certificate, key and client CA files again without a restart. Changed
+// files are also picked up automatically.
+func (s *Server) ReloadTLS() error {
+	s.mu.RLock()
+	t := s.tls
+	s.mu.RUnlock()
+	if t == nil {
+		return errors.New("TLS is not enabled.")
+	}
+	if err := t.reload(); err != nil {
+		s.log.Errorf("Cannot reload TLS certificates: %s", err.Error())
+		return err
+	}
+	s.log.Infof("TLS certificates reloaded.")
+	return nil
+}
+
+// SetConfigLoader sets the function used by Reload to load the options again. By default the
+// configuration file and environment are loaded over the current options.
+func (s *Server) SetConfigLoader(f func() (*Options, error)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loader = f
+}
+
+// Reload loads the options again and applies those that can change while the server is running.
+// Changes to options that require a restart are logged and left as they were.
+func (s *Server) Reload() error {
+	s.mu.RLock()
+	load := s.loader
+	old := s.opts
+	s.mu.RUnlock()
+	ops, err := load()
+	if err != nil {
+		s.log.Errorf("Cannot reload configuration: %s", err.Error())
+		return err
+	}
+	changes := optionsDiff(old, ops)
+	for _, c := range changes {
+		if !c.restart {
+			s.log.Infof("Option %s.", c)
+			continue
+		}
+		s.log.Warningf("Option %s but requires a restart.", c)
+		f, _ := ops.field(c.key)
+		o, _ := old.field(c.key)
+		f.Set(o)
+	}
+	if len(changes) == 0 {
+		s.log.Infof("Configuration reloaded with no changes.")
+	}
+	s.applyOptions(ops)
+	s.mu.Lock()
+	s.opts = ops
+	t := s.tls
+	s.mu.Unlock()
+	if t != nil {
+		s.ReloadTLS()
+	}
+	return nil
+}
+
+// loadOptions is the default configuration loader. It loads the configuration file and
+// environment over the current options.
+func (s *Server) loadOptions() (*Options, error) {
+	s.mu.RLock()
+	o := *s.opts
+	s.mu.RUnlock()
+	if err := o.Load(o.Config); err != nil {
+		return nil, err
+	}
+	return &o, o.Validate()
+}
+
+// applyOptions applies the options that can change while the server is running.
+func (s *Server) applyOptions(ops *Options) {
+	s.cMngr.SetMaxRooms(ops.MaxRooms)
+	s.cMngr.SetMaxIdle(ops.MaxIdle)
+	s.cMngr.SetRateLimits(ops.MsgRate, ops.MsgBurst, ops.JoinRate, ops.MaxThrottle)
+	s.cMngr.SetSizeLimits(ops.MaxFrame, ops.MaxMsgLen, ops.MaxNickLen, ops.MaxRoomLen)
+	filters, err := messageFiltersNew(ops)
+	if err != nil {
+		s.log.Errorf("Cannot load message filters: %s", err.Error())
+	}
+	s.cMngr.SetMessageFilters(filters)
+	s.cMngr.SetTyping(ops.TypingTTL, ops.TypingIdle)
+	s.cMngr.SetAwayIdle(ops.AwayIdle)
+	s.cMngr.SetLiveness(ops.PingPeriod, ops.PongWait, ops.WriteWait)
+	s.cMngr.SetAdmins(ops.Admins)
+	s.cMngr.SetUniqueNicknames(ops.UniqueNick)
+	s.cMngr.SetMOTD(ops.MOTD)
+	s.cMngr.SetBans(ops.Bans)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.info.Name = ops.Name
+	s.info.MaxRooms = ops.MaxRooms
+	s.info.MaxIdle = ops.MaxIdle
+	s.info.MsgRate = ops.MsgRate
+	s.info.MsgBurst = ops.MsgBurst
+	s.info.JoinRate = ops.JoinRate
+	s.info.MaxThrottle = ops.MaxThrottle
+	s.info.Debug = ops.Debug
+	s.secret = nil
+	if ops.AuthSecret != "" {
+		s.secret = []byte(ops.AuthSecret)
+	}
+	s.adminToken = nil
+	if ops.AdminToken != "" {
+		s.adminToken = []byte(ops.AdminToken)
+	}
+	if ops.Debug {
+		s.log.SetLogLevel(logger.Debug)
+	} else {
+		s.log.SetLogLevel(logger.UseDefault)
+	}
+}
+
 // StartProfiler is called to enable dynamic profiling.
 func (s *Server) StartProfiler() {
 	s.log.Infof("Starting profiling on http port %d", s.opts.ProfPort)
@@ -129,27 +305,49 @@ func (s *Server) StartProfiler() {
 	}()
 }
 
-// Shutdown takes down the server gracefully back to an initialize state.
+// Shutdown takes down the server gracefully back to an initialize state. New connections are
+// refused and chatters are told to reconnect later, then given the grace period for queued
+// requests and responses to be delivered before they are disconnected. The server may be
+// started again afterwards.
 func (s *Server) Shutdown() {
-	if !s.isRunning() {
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
 		return
 	}
+	s.running = false
+	srvr, done := s.srvr, s.done
+	grace := time.Duration(s.opts.Grace) * time.Second
+	reconnect := s.opts.Reconnect
+	s.mu.Unlock()
+
 	s.log.Infof("BEGIN server service stop.")
+	s.log.Infof("Refusing new connections...")
+	deadline := time.Now().Add(grace)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	if err := srvr.Shutdown(ctx); err != nil {
+		s.log.Errorf("Cannot close http server: %s", err.Error())
+	}
+	cancel()
+	s.log.Infof("Draining chatters and rooms...")
+	s.cMngr.drain(reconnect, time.Until(deadline))
 	s.log.Infof("Shutting down chatters and rooms...")
 	s.cMngr.shutdownAll()
-	s.mu.Lock()
-	s.running = false
-	s.mu.Unlock()
+	close(done)
 	s.log.Infof("END server service stop.")
 }
 
 // handleSignals responds to operating system interrupts such as application kills.
 func (s *Server) handleSignals() {
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 	go func() {
 		for sig := range c {
 			s.log.Infof("Server received signal: %v\n", sig)
+			if sig == syscall.SIGHUP {
+				s.Reload()
+				continue
+			}
 			s.Shutdown()
 			s.log.Infof("Server exiting.")
 			os.Exit(0)
@@ -157,11 +355,70 @@ func (s *Server) handleSignals() {
 	}()
 }
 
+// chatHandshake validates the origin and, if authentication is enabled, the token of a chat
+// connection before it is accepted. The claims of the token are kept with the connection.
+func (s *Server) chatHandshake(config *websocket.Config, r *http.Request) error {
+	var err error
+	config.Origin, err = websocket.Origin(config, r)
+	if err == nil && config.Origin == nil {
+		return errors.New("null origin")
+	}
+	if err != nil {
+		return err
+	}
+	claims, err := s.authenticate(r)
+	if err != nil {
+		return err
+	}
+	if cc, ok := r.Context().Value(chatConnKey{}).(*chatConn); ok {
+		cc.claims = claims
+	}
+	return nil
+}
+
+// authenticate validates the token of a chat connection and returns its claims. Nil claims are
+// returned if authentication is not enabled.
+func (s *Server) authenticate(r *http.Request) (*authClaims, error) {
+	s.mu.RLock()
+	secret := s.secret
+	s.mu.RUnlock()
+	if secret == nil {
+		return nil, nil
+	}
+	claims, err := authVerify(authToken(r), secret, time.Now())
+	if err != nil {
+		s.log.LogSession("rejected", r.RemoteAddr, fmt.Sprintf("Authentication failed: %s.", err.Error()))
+		return nil, err
+	}
+	return claims, nil
+}
+
 // chatHandler is the main entry point to handle chat connections to the client.
 func (s *Server) chatHandler(ws *websocket.Conn) {
 	s.log.LogConnect(ws.Request())
 	s.incrementStats(ws.Request())
 	chatr := s.cMngr.registerNewChatter(ws)
+	if claims := chatr.conn.verified(); claims != nil {
+		if err := s.cMngr.lockChatter(chatr, claims.Subject); err != nil {
+			s.log.LogSession("rejected", ws.Request().RemoteAddr,
+				fmt.Sprintf(`Nickname "%s" is already in use.`, claims.Subject))
+			chatr.disconnect(ChatRspTypeErrNicknameUsed,
+				fmt.Sprintf(`Nickname "%s" is already in use on the server.`, claims.Subject))
+			s.cMngr.unregisterChatter(chatr)
+			return
+		}
+		s.log.LogSession("authenticated", ws.Request().RemoteAddr,
+			fmt.Sprintf(`Authenticated as "%s".`, claims.Subject))
+	}
+	if s.cMngr.isBanned(chatr) {
+		s.log.LogSession("rejected", ws.Request().RemoteAddr, "Client is banned from the server.")
+		chatr.disconnect(ChatRspTypeErrBanned, "You are banned from the server.")
+		s.cMngr.unregisterChatter(chatr)
+		return
+	}
+	if motd := s.cMngr.MOTD(); motd != "" {
+		chatr.sendResponse("", ChatRspTypeMOTD, motd, nil)
+	}
 	chatr.Run()
 	s.cMngr.unregisterChatter(chatr)
 }
@@ -182,6 +439,7 @@ func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
 	defer s.mu.Unlock()
 	s.stats.ChatterStats = s.cMngr.getChatterStats()
 	s.stats.RoomStats = s.cMngr.getRoomStats()
+	s.stats.Throttled, s.stats.FloodKicks = s.cMngr.throttleStats()
 	mStats := &runtime.MemStats{}
 	runtime.ReadMemStats(mStats)
 	b, _ := json.Marshal(
@@ -199,6 +457,15 @@ func (s *Server) statsHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(b)
 }
 
+// metricsHandler handles a request for metrics in the Prometheus text exposition format.
+func (s *Server) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	s.log.LogConnect(r)
+	s.incrementStats(r)
+	s.initResponseHeader(w)
+	w.Header().Set("Content-Type", metricsContentType)
+	s.writeMetrics(w)
+}
+
 // initResponseHeader sets up the common http response headers for the return of all json calls.
 func (s *Server) initResponseHeader(w http.ResponseWriter) {
 	h := w.Header()
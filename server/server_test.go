@@ -1,10 +1,15 @@
 package server
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"runtime"
+	"sort"
+	"strings"
 	"testing"
 	"time"
 
@@ -35,11 +40,13 @@ var (
 	testRoomReqs        uint64
 	testRoomRsps        uint64
 
-	testSrvr         *Server
-	testSrvrURL      = fmt.Sprintf("ws://%s:%d/v1.0/chat", testServerHostname, testServerPort)
-	testSrvrURLAlive = fmt.Sprintf("http://%s:%d/v1.0/alive", testServerHostname, testServerPort)
-	testSrvrURLStats = fmt.Sprintf("http://%s:%d/v1.0/stats", testServerHostname, testServerPort)
-	testSrvrOrg      = fmt.Sprintf("ws://%s/", testServerHostname)
+	testSrvr           *Server
+	testSrvrURL        = fmt.Sprintf("ws://%s:%d/v1.0/chat", testServerHostname, testServerPort)
+	testSrvrURLAlive   = fmt.Sprintf("http://%s:%d/v1.0/alive", testServerHostname, testServerPort)
+	testSrvrURLStats   = fmt.Sprintf("http://%s:%d/v1.0/stats", testServerHostname, testServerPort)
+	testSrvrURLMetrics = fmt.Sprintf("http://%s:%d/metrics", testServerHostname, testServerPort)
+	testSrvrURLAdmin   = fmt.Sprintf("http://%s:%d/v1.0/admin/", testServerHostname, testServerPort)
+	testSrvrOrg        = fmt.Sprintf("ws://%s/", testServerHostname)
 
 	TestServerSetNickname = fmt.Sprintf(`{"reqType":%d,"content":"%s"}`,
 		ChatReqTypeSetNickname, testChatterNickname1)
@@ -93,8 +100,9 @@ var (
 	TestServerListNames     = fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName1, ChatReqTypeListNames)
 	TestServerListNamesExp0 = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"","list":[]}`,
 		testChatRoomName1, ChatRspTypeListNames)
-	TestServerListNamesExp1 = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"","list":["%s"]}`,
-		testChatRoomName1, ChatRspTypeListNames, testChatterNickname1)
+	TestServerListNamesExp1 = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"","list":["%s"],`+
+		`"presence":{"%s":"online"}}`, testChatRoomName1, ChatRspTypeListNames, testChatterNickname1,
+		testChatterNickname1)
 
 	TestServerHideNickname    = fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName1, ChatReqTypeHide)
 	TestServerHideNicknameExp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"You are now hidden in room \"%s\".","list":[]}`,
@@ -106,8 +114,10 @@ var (
 
 	TestServerMsg = fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Hello you monkeys."}`,
 		testChatRoomName1, ChatReqTypeMsg)
-	TestServerMsgExp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"%s: Hello you monkeys.","list":[]}`,
-		testChatRoomName1, ChatRspTypeMsg, testChatterNickname1)
+	TestServerMsgExp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"%s: Hello you monkeys.","list":[],`+
+		`"message":{"id":1,"nickname":"%s","time":"`, testChatRoomName1, ChatRspTypeMsg, testChatterNickname1,
+		testChatterNickname1)
+	TestServerMsgExpText    = `"text":"Hello you monkeys."}}`
 	TestServerMsgExpErrHide = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"Nickname \"%s\" `+
 		`is hidden. Cannot post in room \"%s\".","list":[]}`,
 		testChatRoomName1, ChatRspTypeErrHiddenNickname, testChatterNickname1, testChatRoomName1)
@@ -129,17 +139,127 @@ func tTestIncrRoomStats() {
 	testRoomRsps++
 }
 
+// tTestSendReceive sends a request to the server and returns the next response.
+func tTestSendReceive(ws *websocket.Conn, req string) (string, error) {
+	if _, err := ws.Write([]byte(req)); err != nil {
+		return "", err
+	}
+	return tTestReceive(ws)
+}
+
+// tTestReceive returns the next response from the server.
+func tTestReceive(ws *websocket.Conn) (string, error) {
+	var rsp = make([]byte, 4096)
+	n, err := ws.Read(rsp)
+	if err != nil {
+		return "", err
+	}
+	return string(rsp[:n]), nil
+}
+
+// tTestDial connects to the server and sets a nickname for the connection.
+func tTestDial(t *testing.T, nickname string) *websocket.Conn {
+	ws, err := websocket.Dial(testSrvrURL, "", testSrvrOrg)
+	if err != nil {
+		t.Fatalf("Server dialing error: %s", err)
+	}
+	req := fmt.Sprintf(`{"reqType":%d,"content":"%s"}`, ChatReqTypeSetNickname, nickname)
+	if _, err := tTestSendReceive(ws, req); err != nil {
+		t.Fatalf("Set nickname error: %s", err)
+	}
+	return ws
+}
+
+// tTestExpect sends a request and compares the response to the expected result.
+func tTestExpect(t *testing.T, ws *websocket.Conn, desc string, req string, exp string) {
+	result, err := tTestSendReceive(ws, req)
+	if err != nil {
+		t.Errorf("%s websocket error: %s", desc, err)
+		return
+	}
+	if result != exp {
+		t.Errorf("%s error.\nExpected: %s\n\nActual: %s\n", desc, exp, result)
+	}
+}
+
+// tTestExpectRsp sends a request, if any, and compares the type and content of the next response.
+func tTestExpectRsp(t *testing.T, ws *websocket.Conn, desc string, req string, rspt int, cont string) {
+	if req != "" {
+		if _, err := ws.Write([]byte(req)); err != nil {
+			t.Errorf("%s websocket error: %s", desc, err)
+			return
+		}
+	}
+	result, err := tTestReceive(ws)
+	if err != nil {
+		t.Errorf("%s websocket error: %s", desc, err)
+		return
+	}
+	var rsp ChatResponse
+	if err := json.Unmarshal([]byte(result), &rsp); err != nil || rsp.RspType != rspt || rsp.Content != cont {
+		t.Errorf("%s error.\nExpected: %d %s\n\nActual: %s\n", desc, rspt, cont, result)
+	}
+}
+
+// tTestExpectReceive reads the next response and compares it to the expected result.
+func tTestExpectReceive(t *testing.T, ws *websocket.Conn, desc string, exp string) {
+	result, err := tTestReceive(ws)
+	if err != nil {
+		t.Errorf("%s websocket error: %s", desc, err)
+		return
+	}
+	if result != exp {
+		t.Errorf("%s error.\nExpected: %s\n\nActual: %s\n", desc, exp, result)
+	}
+}
+
+// tTestNotInRoom validates a nickname is not a member of a room, hidden or not.
+func tTestNotInRoom(t *testing.T, desc string, room string, nickname string) {
+	r, err := testSrvr.cMngr.find(room)
+	if err != nil {
+		t.Errorf("%s room error: %s", desc, err)
+		return
+	}
+	for _, c := range r.ChatRoomStatsNew().Chatters {
+		if c.Nickname == nickname {
+			t.Errorf("%s error. %s should not be a member of room %s.", desc, nickname, room)
+		}
+	}
+}
+
+// tTestProve marks the chatter using a nickname as identified for it, as a registered nickname would.
+func tTestProve(t *testing.T, nickname string) {
+	c, err := testSrvr.cMngr.findChatter(nickname)
+	if err != nil {
+		t.Fatalf("Find chatter %s error: %s", nickname, err)
+	}
+	c.prove(nickname)
+}
+
+// tTestOwner proves the nickname of a chatter and makes the chatter the owner of a room.
+func tTestOwner(t *testing.T, room string, nickname string) {
+	tTestProve(t, nickname)
+	r, err := testSrvr.cMngr.find(room)
+	if err != nil {
+		t.Fatalf("Find room %s error: %s", room, err)
+	}
+	c, _ := testSrvr.cMngr.findChatter(nickname)
+	r.SetOwner(c)
+}
+
 func TestServerStartup(t *testing.T) {
 	opts := &Options{
-		Name:     "Test Server",
-		Hostname: testServerHostname,
-		Port:     testServerPort,
-		ProfPort: 6060,
-		MaxConns: testServerMaxConns,
-		MaxRooms: testServerMaxRooms,
-		MaxIdle:  0,
-		MaxProcs: 1,
-		Debug:    true,
+		Name:      "Test Server",
+		Hostname:  testServerHostname,
+		Port:      testServerPort,
+		ProfPort:  6060,
+		MaxConns:  testServerMaxConns,
+		MaxRooms:  testServerMaxRooms,
+		MaxIdle:   0,
+		MaxProcs:  1,
+		Grace:     1,
+		Reconnect: 3,
+		Debug:     true,
 	}
 	runtime.GOMAXPROCS(1)
 	testSrvr = New(opts)
@@ -391,8 +511,9 @@ func TestServerValidWSSession(t *testing.T) {
 		}
 	}
 	result = string(rsp[:n])
-	if result != TestServerMsgExp {
-		t.Errorf("Send message error.\nExpected: %s\n\nActual: %s\n", TestServerMsgExp, result)
+	if !strings.HasPrefix(result, TestServerMsgExp) || !strings.HasSuffix(result, TestServerMsgExpText) {
+		t.Errorf("Send message error.\nExpected: %s...%s\n\nActual: %s\n", TestServerMsgExp,
+			TestServerMsgExpText, result)
 	}
 
 	// Leave the room
@@ -629,7 +750,7 @@ func TestAdditionalManagerFuncs(t *testing.T) {
 	// Positive Cases
 
 	//  Delete room
-	err := testSrvr.cMngr.deleteRoom(testChatRoomName1)
+	err := testSrvr.cMngr.deleteRoom(testChatRoomName1, nil)
 	if err != nil {
 		t.Errorf("Room %s should have been deleted. Err: %s", testChatRoomName1, err)
 	}
@@ -641,7 +762,7 @@ func TestAdditionalManagerFuncs(t *testing.T) {
 	}
 
 	//  Rename room
-	err = testSrvr.cMngr.renameRoom(testChatRoomName4, testChatRoomName1)
+	err = testSrvr.cMngr.renameRoom(testChatRoomName4, testChatRoomName1, nil)
 	if err != nil {
 		t.Errorf("Room should have been renamed from %s to %s. Err: %s",
 			testChatRoomName4, testChatRoomName1, err)
@@ -692,7 +813,7 @@ func TestAdditionalManagerFuncs(t *testing.T) {
 	}
 
 	// Should not delete a room if it doesnt exist.
-	err = testSrvr.cMngr.deleteRoom(testChatRoomName4)
+	err = testSrvr.cMngr.deleteRoom(testChatRoomName4, nil)
 	if err == nil || err != chatManagerErrRoomNotFound {
 		t.Errorf("Room %s should not have been deleted. Expected: %s Actual: %s",
 			testChatRoomName1, chatManagerErrRoomNotFound, err)
@@ -700,27 +821,27 @@ func TestAdditionalManagerFuncs(t *testing.T) {
 	}
 
 	// Should not delete a room if someone still in it.
-	err = testSrvr.cMngr.deleteRoom(testChatRoomName1)
+	err = testSrvr.cMngr.deleteRoom(testChatRoomName1, nil)
 	if err == nil || err != chatManagerErrRoomNotEmpty {
 		t.Errorf("Room %s delete error. Expected: %s Actual: %s",
 			testChatRoomName1, chatManagerErrRoomNotEmpty, err)
 	}
 
 	// Should not rename a room if old room doesnt exist.
-	err = testSrvr.cMngr.renameRoom(testChatRoomName4, testChatRoomName4)
+	err = testSrvr.cMngr.renameRoom(testChatRoomName4, testChatRoomName4, nil)
 	if err == nil || err != chatManagerErrRoomNotFound {
 		t.Errorf("Room %s should not have been deleted. Expected: %s Actual: %s",
 			testChatRoomName1, chatManagerErrRoomNotFound, err)
 	}
 
 	// Should not rename a room if new room exists.
-	err = testSrvr.cMngr.renameRoom(testChatRoomName1, testChatRoomName1)
+	err = testSrvr.cMngr.renameRoom(testChatRoomName1, testChatRoomName1, nil)
 	if err == nil || err != chatManagerErrRoomExists {
 		t.Errorf("Room rename error. Expected: %s Actual: %s", chatManagerErrRoomExists, err)
 	}
 
 	// Should not rename a room if someone still in it.
-	err = testSrvr.cMngr.renameRoom(testChatRoomName1, testChatRoomName4)
+	err = testSrvr.cMngr.renameRoom(testChatRoomName1, testChatRoomName4, nil)
 	if err == nil || err != chatManagerErrRoomNotEmpty {
 		t.Errorf("Room rename error. Expected: %s Actual: %s", chatManagerErrRoomNotEmpty, err)
 	}
@@ -988,6 +1109,896 @@ func TestServerWSErrorSession(t *testing.T) {
 	testSrvr.cMngr.SetMaxIdle(0) // Reset
 }
 
+func TestServerPrivateMsg(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+
+	// Server wide.
+	pm := fmt.Sprintf(`{"reqType":%d,"target":"%s","content":"Psst."}`, ChatReqTypePrivateMsg,
+		testChatterNickname2)
+	exp := fmt.Sprintf(`{"roomName":"","rspType":%d,"content":"%s: Psst.","list":["%s","%s"]}`,
+		ChatRspTypePrivateMsg, testChatterNickname1, testChatterNickname1, testChatterNickname2)
+	tTestExpect(t, ws1, "Private message", pm, exp)
+	tTestExpectReceive(t, ws2, "Private message receipt", exp)
+
+	pm = fmt.Sprintf(`{"reqType":%d,"target":"Nobody","content":"Psst."}`, ChatReqTypePrivateMsg)
+	exp = fmt.Sprintf(`{"roomName":"","rspType":%d,"content":"Nickname \"Nobody\" is not on the server.",`+
+		`"list":[]}`, ChatRspTypeErrNicknameUnknown)
+	tTestExpect(t, ws1, "Private message unknown", pm, exp)
+	tTestExpectRsp(t, ws1, "Private message no target", fmt.Sprintf(`{"reqType":%d,"content":"Psst."}`,
+		ChatReqTypePrivateMsg), ChatRspTypeErrNicknameMandatory, "target nickname is mandatory")
+
+	ws3 := tTestDial(t, testChatterNickname2)
+	pm = fmt.Sprintf(`{"reqType":%d,"target":"%s","content":"Psst."}`, ChatReqTypePrivateMsg,
+		testChatterNickname2)
+	exp = fmt.Sprintf(`{"roomName":"","rspType":%d,"content":"Nickname \"%s\" is used by more than one `+
+		`chatter.","list":[]}`, ChatRspTypeErrNicknameAmbiguous, testChatterNickname2)
+	tTestExpect(t, ws1, "Private message ambiguous", pm, exp)
+	ws3.Close()
+
+	// Room scoped.
+	pm = fmt.Sprintf(`{"roomName":"%s","reqType":%d,"target":"%s","content":"Psst."}`, testChatRoomName1,
+		ChatReqTypePrivateMsg, testChatterNickname2)
+	exp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"You are not a member of room \"%s\".",`+
+		`"list":[]}`, testChatRoomName1, ChatRspTypeErrNotMember, testChatRoomName1)
+	tTestExpect(t, ws1, "Private message non member", pm, exp)
+
+	tTestSendReceive(ws1, TestServerJoin)
+	exp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"Nickname \"%s\" is not in room \"%s\".",`+
+		`"list":[]}`, testChatRoomName1, ChatRspTypeErrNicknameUnknown, testChatterNickname2, testChatRoomName1)
+	tTestExpect(t, ws1, "Private message not in room", pm, exp)
+
+	tTestSendReceive(ws2, TestServerJoin)
+	tTestReceive(ws1) // join notice
+	exp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"%s: Psst.","list":["%s","%s"]}`,
+		testChatRoomName1, ChatRspTypePrivateMsg, testChatterNickname1, testChatterNickname1, testChatterNickname2)
+	tTestExpect(t, ws1, "Private room message", pm, exp)
+	tTestExpectReceive(t, ws2, "Private room message receipt", exp)
+	tTestExpectRsp(t, ws1, "Private room message no target", fmt.Sprintf(`{"roomName":"%s","reqType":%d,`+
+		`"content":"Psst."}`, testChatRoomName1, ChatReqTypePrivateMsg), ChatRspTypeErrNicknameMandatory,
+		"target nickname is mandatory")
+	room, _ := testSrvr.cMngr.find(testChatRoomName1)
+	from, _ := testSrvr.cMngr.findChatter(testChatterNickname1)
+	if req, err := ChatRequestNew(from, testChatRoomName1, ChatReqTypePrivateMsg, "Psst."); err == nil {
+		room.reqq <- req
+	}
+	tTestExpectRsp(t, ws1, "Private room message no target in room", "", ChatRspTypeErrNicknameMandatory,
+		"target nickname is mandatory")
+
+	tTestSendReceive(ws1, TestServerHideNickname)
+	tTestExpect(t, ws1, "Private message hidden", pm, TestServerMsgExpErrHide)
+}
+
+func TestServerRoomAdmin(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestOwner(t, testChatRoomName2, testChatterNickname1)
+	rename := fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"%s"}`, testChatRoomName2,
+		ChatReqTypeRenameRoom, testChatRoomName3)
+	tTestExpectRsp(t, ws2, "Rename room not member", rename, ChatRspTypeErrNotMember,
+		fmt.Sprintf(`You are not a member of room "%s".`, testChatRoomName2))
+	tTestSendReceive(ws2, TestServerJoin2)
+	tTestReceive(ws1)
+	exp := fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"You are not authorized to administer `+
+		`room \"%s\".","list":[]}`, testChatRoomName2, ChatRspTypeErrNotAuthorized, testChatRoomName2)
+	tTestExpect(t, ws2, "Rename room not owner", rename, exp)
+
+	req := fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"%s"}`, testChatRoomName2,
+		ChatReqTypeRenameRoom, testChatRoomName1)
+	exp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"room already exists","list":[]}`,
+		testChatRoomName2, ChatRspTypeErrRoomExists)
+	tTestExpect(t, ws1, "Rename room exists", req, exp)
+
+	req = fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"%s"}`, testChatRoomName4,
+		ChatReqTypeRenameRoom, testChatRoomName3)
+	exp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"chatroom not found","list":[]}`,
+		testChatRoomName4, ChatRspTypeErrRoomNotFound)
+	tTestExpect(t, ws1, "Rename room not found", req, exp)
+
+	tTestExpectRsp(t, ws1, "Rename room not alone", rename, ChatRspTypeErrRoomNotEmpty, "room is not empty")
+	tTestSendReceive(ws2, fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2, ChatReqTypeLeave))
+	tTestReceive(ws1)
+	exp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"Room \"%s\" renamed to \"%s\".","list":[]}`,
+		testChatRoomName3, ChatRspTypeRenameRoom, testChatRoomName2, testChatRoomName3)
+	tTestExpect(t, ws1, "Rename room", rename, exp)
+
+	req = fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName3, ChatReqTypeDeleteRoom)
+	exp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"Room \"%s\" deleted.","list":[]}`,
+		testChatRoomName3, ChatRspTypeDeleteRoom, testChatRoomName3)
+	tTestExpect(t, ws1, "Delete room", req, exp)
+
+	// Admins may administer rooms they do not own, but not while they are in use.
+	testSrvr.cMngr.SetAdmins([]string{testChatterNickname1})
+	tTestSendReceive(ws1, TestServerJoin)
+	tTestSendReceive(ws2, TestServerJoin)
+	tTestReceive(ws1)
+	req = fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName1, ChatReqTypeDeleteRoom)
+	exp = fmt.Sprintf(`{"roomName":"%s","rspType":%d,"content":"room is not empty","list":[]}`,
+		testChatRoomName1, ChatRspTypeErrRoomNotEmpty)
+	tTestExpect(t, ws1, "Delete room not empty", req, exp)
+	testSrvr.cMngr.SetAdmins(nil)
+}
+
+func TestServerModeration(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestOwner(t, testChatRoomName2, testChatterNickname1)
+	tTestSendReceive(ws2, TestServerJoin2)
+	tTestReceive(ws1)
+
+	mod := func(reqt int, target string) string {
+		return fmt.Sprintf(`{"roomName":"%s","reqType":%d,"target":"%s"}`, testChatRoomName2, reqt, target)
+	}
+	msg := fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Hi."}`, testChatRoomName2, ChatReqTypeMsg)
+	notAuth := fmt.Sprintf(`You are not authorized to moderate "%s" in room "%s".`, testChatterNickname1,
+		testChatRoomName2)
+
+	tTestExpectRsp(t, ws2, "Kick by member", mod(ChatReqTypeKick, testChatterNickname1),
+		ChatRspTypeErrNotAuthorized, notAuth)
+	tTestExpectRsp(t, ws1, "Kick without target", mod(ChatReqTypeKick, ""),
+		ChatRspTypeErrNicknameMandatory, "target nickname is mandatory")
+
+	// Mute
+	cont := fmt.Sprintf("%s was muted by %s.", testChatterNickname2, testChatterNickname1)
+	tTestExpectRsp(t, ws1, "Mute", mod(ChatReqTypeMute, testChatterNickname2), ChatRspTypeMute, cont)
+	tTestExpectRsp(t, ws2, "Mute notice", "", ChatRspTypeMute, cont)
+	tTestExpectRsp(t, ws2, "Muted message", msg, ChatRspTypeErrMuted,
+		fmt.Sprintf(`Nickname "%s" is muted. Cannot post in room "%s".`, testChatterNickname2, testChatRoomName2))
+	cont = fmt.Sprintf("%s was unmuted by %s.", testChatterNickname2, testChatterNickname1)
+	tTestExpectRsp(t, ws1, "Unmute", mod(ChatReqTypeUnmute, testChatterNickname2), ChatRspTypeUnmute, cont)
+	tTestExpectRsp(t, ws2, "Unmute notice", "", ChatRspTypeUnmute, cont)
+
+	// Operators
+	cont = fmt.Sprintf("%s is now an operator, granted by %s.", testChatterNickname2, testChatterNickname1)
+	tTestExpectRsp(t, ws1, "Grant op", mod(ChatReqTypeGrantOp, testChatterNickname2), ChatRspTypeGrantOp, cont)
+	tTestExpectRsp(t, ws2, "Grant op notice", "", ChatRspTypeGrantOp, cont)
+	tTestExpectRsp(t, ws2, "Kick owner by op", mod(ChatReqTypeKick, testChatterNickname1),
+		ChatRspTypeErrNotAuthorized, notAuth)
+	cont = fmt.Sprintf("%s is no longer an operator, revoked by %s.", testChatterNickname2, testChatterNickname1)
+	tTestExpectRsp(t, ws1, "Revoke op", mod(ChatReqTypeRevokeOp, testChatterNickname2), ChatRspTypeRevokeOp, cont)
+	tTestExpectRsp(t, ws2, "Revoke op notice", "", ChatRspTypeRevokeOp, cont)
+
+	// Kick
+	cont = fmt.Sprintf("%s was kicked by %s.", testChatterNickname2, testChatterNickname1)
+	tTestExpectRsp(t, ws1, "Kick", mod(ChatReqTypeKick, testChatterNickname2), ChatRspTypeKick, cont)
+	tTestExpectRsp(t, ws2, "Kicked", "", ChatRspTypeKick, fmt.Sprintf(`You have been kicked from room "%s" by %s.`,
+		testChatRoomName2, testChatterNickname1))
+	tTestSendReceive(ws2, TestServerJoin2)
+	tTestReceive(ws1)
+
+	// Ban
+	cont = fmt.Sprintf("%s was banned by %s.", testChatterNickname2, testChatterNickname1)
+	tTestExpectRsp(t, ws1, "Ban", mod(ChatReqTypeBan, testChatterNickname2), ChatRspTypeBan, cont)
+	tTestExpectRsp(t, ws2, "Banned", "", ChatRspTypeBan, fmt.Sprintf(`You have been banned from room "%s" by %s.`,
+		testChatRoomName2, testChatterNickname1))
+	banned := fmt.Sprintf(`You are banned from room "%s".`, testChatRoomName2)
+	tTestExpectRsp(t, ws2, "Banned join", TestServerJoin2, ChatRspTypeErrBanned, banned)
+	notMember := fmt.Sprintf(`You are not a member of room "%s".`, testChatRoomName2)
+	for _, reqt := range []int{ChatReqTypeHide, ChatReqTypeUnhide} {
+		tTestExpectRsp(t, ws2, "Banned hide", fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2, reqt),
+			ChatRspTypeErrNotMember, notMember)
+	}
+	tTestNotInRoom(t, "Banned hide", testChatRoomName2, testChatterNickname2)
+	ws3 := tTestDial(t, "SameIP")
+	tTestExpectRsp(t, ws3, "Banned IP join", TestServerJoin2, ChatRspTypeErrBanned, banned)
+	ws3.Close()
+	cont = fmt.Sprintf("%s was unbanned by %s.", testChatterNickname2, testChatterNickname1)
+	tTestExpectRsp(t, ws1, "Unban", mod(ChatReqTypeUnban, testChatterNickname2), ChatRspTypeUnban, cont)
+	tTestExpectRsp(t, ws2, "Unbanned join", TestServerJoin2, ChatRspTypeJoin,
+		fmt.Sprintf("%s has joined the room.", testChatterNickname2))
+	tTestReceive(ws1)
+
+	// Roles are used from inside the room and belong to the proven identity, not the nickname.
+	tTestSendReceive(ws1, fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2, ChatReqTypeLeave))
+	tTestReceive(ws2)
+	tTestExpectRsp(t, ws1, "Kick not member", mod(ChatReqTypeKick, testChatterNickname2), ChatRspTypeErrNotMember,
+		notMember)
+	ws1.Close()
+	ws4 := tTestDial(t, testChatterNickname1)
+	defer ws4.Close()
+	tTestSendReceive(ws4, TestServerJoin2)
+	tTestReceive(ws2)
+	tTestExpectRsp(t, ws4, "Kick by same nickname", mod(ChatReqTypeKick, testChatterNickname2),
+		ChatRspTypeErrNotAuthorized, fmt.Sprintf(`You are not authorized to moderate "%s" in room "%s".`,
+			testChatterNickname2, testChatRoomName2))
+}
+
+func TestServerRoomModes(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestOwner(t, testChatRoomName2, testChatterNickname1)
+
+	lock := fmt.Sprintf(`{"roomName":"%s","reqType":%d,"password":"secret"}`, testChatRoomName2,
+		ChatReqTypeSetPassword)
+	tTestExpectRsp(t, ws2, "Set password not member", lock, ChatRspTypeErrNotMember,
+		fmt.Sprintf(`You are not a member of room "%s".`, testChatRoomName2))
+	tTestExpectRsp(t, ws1, "Set password", lock, ChatRspTypeSetPassword,
+		"The room now requires a password to join.")
+
+	join := `{"roomName":"%s","reqType":%d,"password":"%s"}`
+	wrong := fmt.Sprintf(`Wrong password for room "%s".`, testChatRoomName2)
+	tTestExpectRsp(t, ws2, "Join no password", TestServerJoin2, ChatRspTypeErrWrongPassword, wrong)
+	tTestExpectRsp(t, ws2, "Join wrong password", fmt.Sprintf(join, testChatRoomName2, ChatReqTypeJoin, "guess"),
+		ChatRspTypeErrWrongPassword, wrong)
+	joined := fmt.Sprintf("%s has joined the room.", testChatterNickname2)
+	tTestExpectRsp(t, ws2, "Join password", fmt.Sprintf(join, testChatRoomName2, ChatReqTypeJoin, "secret"),
+		ChatRspTypeJoin, joined)
+	tTestReceive(ws1)
+	tTestSendReceive(ws2, fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2, ChatReqTypeLeave))
+	tTestReceive(ws1)
+
+	unlock := fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2, ChatReqTypeSetPassword)
+	tTestExpectRsp(t, ws1, "Clear password", unlock, ChatRspTypeSetPassword,
+		"The room no longer requires a password to join.")
+	inviteOnly := fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"on"}`, testChatRoomName2,
+		ChatReqTypeSetInviteOnly)
+	tTestExpectRsp(t, ws1, "Invite only", inviteOnly, ChatRspTypeSetInviteOnly, "The room is now invite only.")
+	tTestExpectRsp(t, ws2, "Join not invited", TestServerJoin2, ChatRspTypeErrNotInvited,
+		fmt.Sprintf(`You have not been invited to room "%s".`, testChatRoomName2))
+	notMember := fmt.Sprintf(`You are not a member of room "%s".`, testChatRoomName2)
+	for _, reqt := range []int{ChatReqTypeHide, ChatReqTypeUnhide} {
+		tTestExpectRsp(t, ws2, "Uninvited hide", fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2,
+			reqt), ChatRspTypeErrNotMember, notMember)
+	}
+	tTestNotInRoom(t, "Uninvited hide", testChatRoomName2, testChatterNickname2)
+
+	invite := fmt.Sprintf(`{"roomName":"%s","reqType":%d,"target":"%s"}`, testChatRoomName2, ChatReqTypeInvite,
+		testChatterNickname2)
+	tTestExpectRsp(t, ws1, "Invite", invite, ChatRspTypeInvite,
+		fmt.Sprintf(`%s is invited to room "%s".`, testChatterNickname2, testChatRoomName2))
+	tTestExpectRsp(t, ws2, "Invited", "", ChatRspTypeInvite,
+		fmt.Sprintf(`You have been invited to room "%s" by %s.`, testChatRoomName2, testChatterNickname1))
+	tTestExpectRsp(t, ws2, "Join invited", TestServerJoin2, ChatRspTypeJoin, joined)
+	tTestReceive(ws1)
+	tTestExpectRsp(t, ws1, "Invite only off", strings.Replace(inviteOnly, `"on"`, `"off"`, 1),
+		ChatRspTypeSetInviteOnly, "The room is no longer invite only.")
+}
+
+func TestServerAuthentication(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	testSrvr.mu.Lock()
+	testSrvr.secret = []byte(testAuthSecret)
+	testSrvr.mu.Unlock()
+	defer func() {
+		testSrvr.mu.Lock()
+		testSrvr.secret = nil
+		testSrvr.mu.Unlock()
+	}()
+
+	if ws, err := websocket.Dial(testSrvrURL, "", testSrvrOrg); err == nil {
+		ws.Close()
+		t.Errorf("Connection without a token should have been rejected.")
+	}
+	tok := tTestAuthSign("HS256", fmt.Sprintf(`{"sub":"%s","exp":%d}`, testChatterNickname2,
+		time.Now().Add(-time.Minute).Unix()), testAuthSecret)
+	if ws, err := websocket.Dial(testSrvrURL+"?token="+tok, "", testSrvrOrg); err == nil {
+		ws.Close()
+		t.Errorf("Connection with an expired token should have been rejected.")
+	}
+
+	tok = tTestAuthSign("HS256", fmt.Sprintf(`{"sub":"%s","exp":%d}`, testChatterNickname2,
+		time.Now().Add(time.Minute).Unix()), testAuthSecret)
+	ws, err := websocket.Dial(testSrvrURL+"?token="+tok, "", testSrvrOrg)
+	if err != nil {
+		t.Fatalf("Connection with a valid token should have been accepted: %s", err)
+	}
+	defer ws.Close()
+	tTestExpectRsp(t, ws, "Authenticated nickname", TestServerGetNickname, ChatRspTypeGetNickname,
+		testChatterNickname2)
+	tTestExpectRsp(t, ws, "Locked nickname", TestServerSetNickname, ChatRspTypeErrNicknameLocked,
+		fmt.Sprintf(`Nickname is locked to "%s".`, testChatterNickname2))
+
+	testSrvr.cMngr.SetUniqueNicknames(true)
+	defer testSrvr.cMngr.SetUniqueNicknames(false)
+	ws2, err := websocket.Dial(testSrvrURL+"?token="+tok, "", testSrvrOrg)
+	if err != nil {
+		t.Fatalf("Connection with a valid token should have been accepted: %s", err)
+	}
+	defer ws2.Close()
+	tTestExpectRsp(t, ws2, "Authenticated nickname in use", "", ChatRspTypeErrNicknameUsed,
+		fmt.Sprintf(`Nickname "%s" is already in use on the server.`, testChatterNickname2))
+}
+
+func TestServerNicknameRegistry(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	const nick = "RegisteredMonkey"
+	ws1 := tTestDial(t, nick)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+
+	tTestExpectRsp(t, ws1, "Register no password", fmt.Sprintf(`{"reqType":%d}`, ChatReqTypeRegister),
+		ChatRspTypeErrPasswordMandatory, "password is mandatory")
+	tTestExpectRsp(t, ws1, "Register long password", fmt.Sprintf(`{"reqType":%d,"password":"%s"}`,
+		ChatReqTypeRegister, strings.Repeat("x", maxNicknamePassword+1)), ChatRspTypeErrTooLong, "password is too long")
+	tTestExpectRsp(t, ws1, "Register", fmt.Sprintf(`{"reqType":%d,"password":"secret"}`, ChatReqTypeRegister),
+		ChatRspTypeRegister, fmt.Sprintf(`Nickname "%s" registered.`, nick))
+	tTestExpectRsp(t, ws2, "Set registered nickname", fmt.Sprintf(`{"reqType":%d,"content":"%s"}`,
+		ChatReqTypeSetNickname, nick), ChatRspTypeErrNicknameRegistered,
+		fmt.Sprintf(`Nickname "%s" is registered. Identify to use it.`, nick))
+	tTestExpectRsp(t, ws2, "Identify wrong password", fmt.Sprintf(`{"reqType":%d,"content":"%s","password":"x"}`,
+		ChatReqTypeIdentify, nick), ChatRspTypeErrWrongPassword, fmt.Sprintf(`Wrong password for nickname "%s".`, nick))
+	tTestExpectRsp(t, ws2, "Identify unknown", fmt.Sprintf(`{"reqType":%d,"content":"Nobody","password":"x"}`,
+		ChatReqTypeIdentify), ChatRspTypeErrNicknameNotRegistered, `Nickname "Nobody" is not registered.`)
+	tTestExpectRsp(t, ws2, "Identify", fmt.Sprintf(`{"reqType":%d,"content":"%s","password":"secret"}`,
+		ChatReqTypeIdentify, nick), ChatRspTypeIdentify, fmt.Sprintf(`Identified as "%s".`, nick))
+	tTestExpectRsp(t, ws2, "Identified nickname", TestServerGetNickname, ChatRspTypeGetNickname, nick)
+	tTestExpectRsp(t, ws2, "Drop", fmt.Sprintf(`{"reqType":%d,"password":"secret"}`, ChatReqTypeDropNickname),
+		ChatRspTypeDropNickname, fmt.Sprintf(`Nickname "%s" dropped.`, nick))
+	tTestExpectRsp(t, ws2, "Drop again", fmt.Sprintf(`{"reqType":%d,"password":"secret"}`, ChatReqTypeDropNickname),
+		ChatRspTypeErrNicknameNotRegistered, fmt.Sprintf(`Nickname "%s" is not registered.`, nick))
+}
+
+func TestServerNicknameChange(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	const renamed = "RenamedMonkey"
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestSendReceive(ws2, TestServerJoin2)
+	tTestReceive(ws1)
+
+	nick := `{"reqType":%d,"content":"%s"}`
+	tTestExpectRsp(t, ws2, "Nickname used in room", fmt.Sprintf(nick, ChatReqTypeSetNickname, testChatterNickname1),
+		ChatRspTypeErrNicknameUsed, fmt.Sprintf(`Nickname "%s" is already in use in room "%s".`,
+			testChatterNickname1, testChatRoomName2))
+	changed := fmt.Sprintf("%s is now known as %s.", testChatterNickname2, renamed)
+	tTestExpectRsp(t, ws2, "Nickname changed", fmt.Sprintf(nick, ChatReqTypeSetNickname, renamed),
+		ChatRspTypeNicknameChanged, changed)
+	tTestExpectRsp(t, ws2, "Nickname set", "", ChatRspTypeSetNickname, fmt.Sprintf(`Nickname set to "%s".`, renamed))
+	tTestExpectRsp(t, ws1, "Nickname change broadcast", "", ChatRspTypeNicknameChanged, changed)
+	tTestExpectRsp(t, ws1, "Names after change", fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2,
+		ChatReqTypeListNames), ChatRspTypeListNames, "")
+	tTestSendReceive(ws2, fmt.Sprintf(nick, ChatReqTypeSetNickname, testChatterNickname2))
+	tTestReceive(ws1)
+
+	ws3 := tTestDial(t, "UniqueMonkey")
+	defer ws3.Close()
+	testSrvr.cMngr.SetUniqueNicknames(true)
+	defer testSrvr.cMngr.SetUniqueNicknames(false)
+	tTestExpectRsp(t, ws3, "Nickname used on server", fmt.Sprintf(nick, ChatReqTypeSetNickname, testChatterNickname1),
+		ChatRspTypeErrNicknameUsed, fmt.Sprintf(`Nickname "%s" is already in use on the server.`, testChatterNickname1))
+}
+
+func TestServerReload(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	const banned = "BannedMonkey"
+	ws1 := tTestDial(t, banned)
+	defer ws1.Close()
+	testSrvr.mu.RLock()
+	orig := *testSrvr.opts
+	testSrvr.mu.RUnlock()
+	defer func() {
+		testSrvr.SetConfigLoader(func() (*Options, error) { o := orig; return &o, nil })
+		testSrvr.Reload()
+		testSrvr.SetConfigLoader(testSrvr.loadOptions)
+	}()
+
+	testSrvr.SetConfigLoader(func() (*Options, error) { return nil, errors.New("bad config") })
+	if err := testSrvr.Reload(); err == nil {
+		t.Errorf("Reload should fail when the configuration cannot be loaded.")
+	}
+	testSrvr.SetConfigLoader(func() (*Options, error) {
+		o := orig
+		o.Port = testServerPort + 1
+		o.MaxRooms = testServerMaxRooms + 1
+		o.MOTD = "Be nice."
+		o.Bans = []string{banned}
+		return &o, nil
+	})
+	if err := testSrvr.Reload(); err != nil {
+		t.Fatalf("Reload should have succeeded. Err: %s", err)
+	}
+	tTestExpectRsp(t, ws1, "Banned on reload", "", ChatRspTypeErrBanned, "You are banned from the server.")
+	if m := testSrvr.cMngr.MaxRooms(); m != testServerMaxRooms+1 {
+		t.Errorf("Max rooms should have been applied. Actual: %d", m)
+	}
+	testSrvr.mu.RLock()
+	port, maxr := testSrvr.opts.Port, testSrvr.info.MaxRooms
+	testSrvr.mu.RUnlock()
+	if port != testServerPort || maxr != testServerMaxRooms+1 {
+		t.Errorf("Port requires a restart and max rooms should be reported. Actual: %d %d", port, maxr)
+	}
+
+	ws2, err := websocket.Dial(testSrvrURL, "", testSrvrOrg)
+	if err != nil {
+		t.Fatalf("Server dialing error: %s", err)
+	}
+	defer ws2.Close()
+	tTestExpectRsp(t, ws2, "Message of the day", "", ChatRspTypeMOTD, "Be nice.")
+	tTestExpectRsp(t, ws2, "Banned nickname", fmt.Sprintf(`{"reqType":%d,"content":"%s"}`,
+		ChatReqTypeSetNickname, banned), ChatRspTypeErrBanned, fmt.Sprintf(`Nickname "%s" is banned from the server.`,
+		banned))
+}
+
+// tTestAdmin sends an admin API request to the server and returns the status and body.
+func tTestAdmin(t *testing.T, token string, method string, path string, body string) (int, string) {
+	rq, _ := http.NewRequest(method, testSrvrURLAdmin+path, strings.NewReader(body))
+	rq.Header.Add("Authorization", "Bearer "+token)
+	r, err := http.DefaultClient.Do(rq)
+	if err != nil {
+		t.Errorf("Admin request error: %s", err)
+		return 0, ""
+	}
+	b, _ := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	return r.StatusCode, string(b)
+}
+
+func TestServerAdminAPI(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	const token = "AdminToken"
+	testSrvr.mu.Lock()
+	testSrvr.adminToken = []byte(token)
+	testSrvr.mu.Unlock()
+	defer func() {
+		testSrvr.mu.Lock()
+		testSrvr.adminToken = nil
+		testSrvr.mu.Unlock()
+	}()
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestSendReceive(ws2, TestServerJoin2)
+	tTestReceive(ws1)
+
+	if st, _ := tTestAdmin(t, "WrongToken", "GET", "rooms", ""); st != http.StatusUnauthorized {
+		t.Errorf("Admin request with a wrong token should be refused. Actual: %d", st)
+	}
+	st, body := tTestAdmin(t, token, "GET", "rooms/"+testChatRoomName2, "")
+	if st != http.StatusOK || !strings.Contains(body, testChatterNickname2) {
+		t.Errorf("Admin room members incorrect. Actual: %d %s", st, body)
+	}
+	if st, _ := tTestAdmin(t, token, "DELETE", "rooms/"+testChatRoomName2, ""); st != http.StatusConflict {
+		t.Errorf("Admin delete of a room in use should conflict. Actual: %d", st)
+	}
+
+	const notice = "Maintenance at noon."
+	if st, body := tTestAdmin(t, token, "POST", "rooms/"+testChatRoomName2+"/announce",
+		fmt.Sprintf(`{"content":"%s"}`, notice)); st != http.StatusOK {
+		t.Errorf("Admin announce should succeed. Actual: %d %s", st, body)
+	}
+	tTestExpectRsp(t, ws1, "Announcement", "", ChatRspTypeAnnouncement, notice)
+	tTestExpectRsp(t, ws2, "Announcement", "", ChatRspTypeAnnouncement, notice)
+
+	if st, body := tTestAdmin(t, token, "POST", "rooms/"+testChatRoomName2+"/kick",
+		fmt.Sprintf(`{"nickname":"%s"}`, testChatterNickname2)); st != http.StatusOK {
+		t.Errorf("Admin kick should succeed. Actual: %d %s", st, body)
+	}
+	tTestExpectRsp(t, ws2, "Admin kick", "", ChatRspTypeKick,
+		fmt.Sprintf(`You have been kicked from room "%s" by the server administrator.`, testChatRoomName2))
+	tTestExpectRsp(t, ws1, "Admin kick notice", "", ChatRspTypeKick,
+		fmt.Sprintf("%s was kicked by the server administrator.", testChatterNickname2))
+
+	if st, body := tTestAdmin(t, token, "POST", "chatters/"+testChatterNickname1+"/disconnect", "{}"); st != http.StatusOK {
+		t.Errorf("Admin disconnect should succeed. Actual: %d %s", st, body)
+	}
+	tTestExpectRsp(t, ws1, "Admin disconnect", "", ChatRspTypeErrDisconnected,
+		"You have been disconnected by the server administrator.")
+	if _, err := tTestReceive(ws1); err == nil {
+		t.Errorf("Chatter should have been disconnected.")
+	}
+}
+
+func TestServerFloodControl(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	testSrvr.cMngr.SetRateLimits(1, 2, 1, 2)
+	defer testSrvr.cMngr.SetRateLimits(0, 0, 0, 0)
+	throttled, kicks := testSrvr.cMngr.throttleStats()
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestExpectRsp(t, ws1, "Join throttled", TestServerJoin, ChatRspTypeErrThrottled,
+		"You are joining rooms too fast. Request dropped.")
+
+	msg := fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Flood"}`, testChatRoomName2, ChatReqTypeMsg)
+	tTestExpectRsp(t, ws1, "Message within burst", msg, ChatRspTypeMsg, testChatterNickname1+": Flood")
+	tTestExpectRsp(t, ws1, "Message within burst", msg, ChatRspTypeMsg, testChatterNickname1+": Flood")
+	tTestExpectRsp(t, ws1, "Message over burst", msg, ChatRspTypeErrThrottled,
+		"You are sending messages too fast. Request dropped.")
+	tTestExpectRsp(t, ws1, "Flood disconnect", msg, ChatRspTypeErrThrottled,
+		"Disconnected for sending too many requests.")
+	if _, err := tTestReceive(ws1); err == nil {
+		t.Errorf("Flooding chatter should have been disconnected.")
+	}
+	if th, k := testSrvr.cMngr.throttleStats(); th != throttled+3 || k != kicks+1 {
+		t.Errorf("Throttle stats incorrect. Actual: %d %d", th-throttled, k-kicks)
+	}
+}
+
+func TestServerRequestLimits(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	testSrvr.cMngr.SetSizeLimits(64, 5, 12, 8)
+	defer testSrvr.cMngr.SetSizeLimits(0, 0, 0, 0)
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	tTestSendReceive(ws1, TestServerJoin2)
+
+	big := fmt.Sprintf(`{"reqType":%d,"content":"%s"}`, ChatReqTypeSetNickname, strings.Repeat("X", 64))
+	tTestExpectRsp(t, ws1, "Frame too large", big, ChatRspTypeErrFrameTooLarge, "Request is larger than 64 bytes.")
+	tTestExpect(t, ws1, "Request after large frame", TestServerGetNickname, TestServerGetNicknameExp)
+	tTestExpectRsp(t, ws1, "Invalid UTF-8", fmt.Sprintf("{\"reqType\":%d,\"content\":\"\xff\"}", ChatReqTypeSetNickname),
+		ChatRspTypeErrInvalidUTF8, "Request is not valid UTF-8.")
+	tTestExpectRsp(t, ws1, "Message too long", fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Hello!"}`,
+		testChatRoomName2, ChatReqTypeMsg), ChatRspTypeErrTooLong, "Message is longer than 5 characters.")
+	tTestExpectRsp(t, ws1, "Nickname too long", fmt.Sprintf(`{"reqType":%d,"content":"LongChatMonkey"}`,
+		ChatReqTypeSetNickname), ChatRspTypeErrTooLong, "Nickname is longer than 12 characters.")
+	tTestExpectRsp(t, ws1, "Room name too long", fmt.Sprintf(`{"roomName":"LongRoomName","reqType":%d}`,
+		ChatReqTypeJoin), ChatRspTypeErrTooLong, "Room name is longer than 8 characters.")
+	tTestExpectRsp(t, ws1, "Control character", fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Hi\u0007"}`,
+		testChatRoomName2, ChatReqTypeMsg), ChatRspTypeErrControlChar, "Message contains control characters.")
+	tTestExpectRsp(t, ws1, "Message within limits", fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Hi"}`,
+		testChatRoomName2, ChatReqTypeMsg), ChatRspTypeMsg, testChatterNickname1+": Hi")
+}
+
+func TestServerMessageFilters(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	block, _ := RegexFilterNew([]string{"(?i)spam"})
+	testSrvr.cMngr.SetMessageFilters([]MessageFilter{block, &LinkFilter{}, WordFilterNew([]string{"darn"})})
+	defer testSrvr.cMngr.SetMessageFilters(nil)
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	tTestSendReceive(ws1, TestServerJoin2)
+
+	tTestExpectRsp(t, ws1, "Message rewritten", fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"%s"}`,
+		testChatRoomName2, ChatReqTypeMsg, "Darn, see http://example.com"), ChatRspTypeMsg,
+		testChatterNickname1+": ****, see [link removed]")
+	tTestExpectRsp(t, ws1, "Message rejected", fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"%s"}`,
+		testChatRoomName2, ChatReqTypeMsg, "Cheap SPAM here"), ChatRspTypeErrMessageRejected,
+		"Message contains blocked content.")
+	tTestExpectRsp(t, ws1, "Message passed", fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Hi"}`,
+		testChatRoomName2, ChatReqTypeMsg), ChatRspTypeMsg, testChatterNickname1+": Hi")
+}
+
+func TestServerBots(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	room := testChatRoomName1
+	bot := &tTestBot{room: room, echo: true, rsps: make(chan *ChatResponse, 100)}
+	if err := testSrvr.AddBot("EchoBot", bot); err != nil {
+		t.Fatalf("Bot should be added: %v", err)
+	}
+	tTestBotExpect(t, bot, ChatRspTypeJoin)
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	tTestSendReceive(ws1, fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, room, ChatReqTypeJoin))
+
+	result, _ := tTestSendReceive(ws1, fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, room, ChatReqTypeListNames))
+	var rsp ChatResponse
+	json.Unmarshal([]byte(result), &rsp)
+	sort.Strings(rsp.List)
+	if exp := testChatterNickname1 + ",EchoBot [bot]"; strings.Join(rsp.List, ",") != exp {
+		t.Errorf("Member list should mark bots.\nExpected: %s\n\nActual: %v\n", exp, rsp.List)
+	}
+	tTestExpectRsp(t, ws1, "Message to bot", fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Hi"}`,
+		room, ChatReqTypeMsg), ChatRspTypeMsg, testChatterNickname1+": Hi")
+	tTestExpectRsp(t, ws1, "Bot reply", "", ChatRspTypeMsg, "EchoBot: echo Hi")
+	if err := testSrvr.RemoveBot("EchoBot"); err != nil {
+		t.Errorf("Bot should be removed: %v", err)
+	}
+	tTestExpectRsp(t, ws1, "Bot removed", "", ChatRspTypeLeave, "EchoBot has left the room.")
+
+	if err := testSrvr.AddBot("EchoBot", bot); err != nil {
+		t.Fatalf("Bot should be added: %v", err)
+	}
+	tTestExpectRsp(t, ws1, "Bot joined", "", ChatRspTypeJoin, "EchoBot has joined the room.")
+	testSrvr.cMngr.SetBans([]string{"EchoBot"})
+	defer testSrvr.cMngr.SetBans(nil)
+	tTestExpectRsp(t, ws1, "Banned bot removed", "", ChatRspTypeLeave, "EchoBot has left the room.")
+	if err := testSrvr.RemoveBot("EchoBot"); err != chatManagerErrNicknameNotFound {
+		t.Errorf("Banned bot should have been removed. Actual: %v", err)
+	}
+}
+
+func TestServerSlashCommands(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	testSrvr.cMngr.SetAdmins([]string{testChatterNickname1})
+	defer testSrvr.cMngr.SetAdmins(nil)
+	testSrvr.AddSlashCommand(&SlashCommand{"roll", "", "Roll a die.",
+		func(room string, nickname string, args string) (*ChatRequest, string, error) {
+			return nil, nickname + " rolled 4.", nil
+		}})
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	tTestProve(t, testChatterNickname1)
+	tTestSendReceive(ws1, TestServerJoin2)
+	cmd := func(content string) string {
+		return fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"%s"}`, testChatRoomName2, ChatReqTypeMsg, content)
+	}
+
+	tTestExpectRsp(t, ws1, "Emote", cmd("/me waves"), ChatRspTypeEmote, "* "+testChatterNickname1+" waves")
+	tTestExpectRsp(t, ws1, "Escaped slash", cmd("//me"), ChatRspTypeMsg, testChatterNickname1+": /me")
+	tTestExpectRsp(t, ws1, "Unknown command", cmd("/bogus"), ChatRspTypeErrUnknownCommand,
+		`Unknown command "/bogus". Type /help for a list of commands.`)
+	tTestExpectRsp(t, ws1, "Usage", cmd("/msg"), ChatRspTypeErrCommand, "Usage: /msg NICKNAME MESSAGE")
+	tTestExpectRsp(t, ws1, "Set topic", cmd("/topic Bananas"), ChatRspTypeTopic,
+		testChatterNickname1+" set the topic to: Bananas")
+	tTestExpectRsp(t, ws1, "Get topic", cmd("/topic"), ChatRspTypeTopic,
+		fmt.Sprintf(`The topic of room "%s" is: Bananas`, testChatRoomName2))
+	tTestExpectRsp(t, ws1, "Who", cmd("/who"), ChatRspTypeListNames, "")
+	tTestExpectRsp(t, ws1, "Private message", cmd("/msg "+testChatterNickname1+" psst"), ChatRspTypePrivateMsg,
+		testChatterNickname1+": psst")
+	tTestExpectRsp(t, ws1, "Added command", cmd("/roll"), ChatRspTypeCommand, testChatterNickname1+" rolled 4.")
+	tTestExpectRsp(t, ws1, "Help", cmd("/help"), ChatRspTypeCommand, "Commands:")
+	tTestExpectRsp(t, ws1, "Leave", cmd("/leave"), ChatRspTypeLeave,
+		fmt.Sprintf(`You have left room "%s".`, testChatRoomName2))
+
+	testSrvr.cMngr.SetSizeLimits(0, 0, 12, 8)
+	tTestExpectRsp(t, ws1, "Nickname with newline", cmd(`/nick A\nB`), ChatRspTypeErrControlChar,
+		"Nickname contains control characters.")
+	tTestExpectRsp(t, ws1, "Nickname too long", cmd("/nick ThisNicknameIsWayTooLong"), ChatRspTypeErrTooLong,
+		"Nickname is longer than 12 characters.")
+	tTestExpectRsp(t, ws1, "Room name too long", cmd("/join ThisRoomNameIsTooLong"), ChatRspTypeErrTooLong,
+		"Room name is longer than 8 characters.")
+	testSrvr.cMngr.SetSizeLimits(0, 0, 0, 0)
+
+	testSrvr.cMngr.SetRateLimits(1, 1, 1, 0)
+	defer testSrvr.cMngr.SetRateLimits(0, 0, 0, 0)
+	tTestExpectRsp(t, ws1, "Command within burst", cmd("/bogus"), ChatRspTypeErrUnknownCommand,
+		`Unknown command "/bogus". Type /help for a list of commands.`)
+	tTestExpectRsp(t, ws1, "Command over burst", cmd("/help"), ChatRspTypeErrThrottled,
+		"You are sending messages too fast. Request dropped.")
+	time.Sleep(1 * time.Second)
+	tTestExpectRsp(t, ws1, "Join command", cmd("/join "+testChatRoomName2), ChatRspTypeJoin,
+		fmt.Sprintf("%s has joined the room.", testChatterNickname1))
+	time.Sleep(1 * time.Second)
+	tTestExpectRsp(t, ws1, "Join command over limit", cmd("/join "+testChatRoomName1), ChatRspTypeErrThrottled,
+		"You are joining rooms too fast. Request dropped.")
+}
+
+func TestServerTyping(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	testSrvr.cMngr.SetTyping(1, false)
+	defer testSrvr.cMngr.SetTyping(0, false)
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestSendReceive(ws2, TestServerJoin2)
+	tTestReceive(ws1)
+	typing := func(cont string) string {
+		return fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"%s"}`, testChatRoomName2, ChatReqTypeTyping, cont)
+	}
+	names := fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2, ChatReqTypeListNames)
+
+	ws1.Write([]byte(typing("start")))
+	tTestExpectRsp(t, ws2, "Typing start", "", ChatRspTypeTyping, "start")
+	ws1.Write([]byte(typing("start")))
+	ws1.Write([]byte(typing("stop")))
+	tTestExpectRsp(t, ws2, "Typing stop", "", ChatRspTypeTyping, "stop")
+	tTestExpectRsp(t, ws1, "Typing not sent to the typist", names, ChatRspTypeListNames, "")
+
+	ws1.Write([]byte(typing("start")))
+	tTestExpectRsp(t, ws2, "Typing start again", "", ChatRspTypeTyping, "start")
+	tTestExpectRsp(t, ws2, "Typing expired", "", ChatRspTypeTyping, "stop")
+
+	ws1.Write([]byte(typing("start")))
+	tTestExpectRsp(t, ws2, "Typing before message", "", ChatRspTypeTyping, "start")
+	tTestExpectRsp(t, ws1, "Message ends typing", fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Hi"}`,
+		testChatRoomName2, ChatReqTypeMsg), ChatRspTypeMsg, testChatterNickname1+": Hi")
+	tTestExpectRsp(t, ws2, "Message ends typing", "", ChatRspTypeMsg, testChatterNickname1+": Hi")
+	time.Sleep(1500 * time.Millisecond)
+
+	tTestSendReceive(ws1, fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2, ChatReqTypeHide))
+	ws1.Write([]byte(typing("start")))
+	tTestExpectRsp(t, ws1, "Hidden typist", names, ChatRspTypeListNames, "")
+	tTestExpectRsp(t, ws2, "Hidden typing not sent", names, ChatRspTypeListNames, "")
+
+	testSrvr.cMngr.SetMaxIdle(2)
+	defer testSrvr.cMngr.SetMaxIdle(0)
+	tTestSendReceive(ws1, names)
+	for i := 0; i < 6; i++ {
+		time.Sleep(500 * time.Millisecond)
+		ws1.Write([]byte(typing("start")))
+	}
+	if _, err := tTestReceive(ws1); err == nil {
+		t.Errorf("Typing should not keep an idle chatter connected.")
+	}
+}
+
+func TestServerReceipts(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	testSrvr.cMngr.SetAdmins([]string{testChatterNickname1})
+	defer testSrvr.cMngr.SetAdmins(nil)
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+	tTestProve(t, testChatterNickname1)
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestSendReceive(ws2, TestServerJoin2)
+	tTestReceive(ws1)
+	req := func(reqt int, cont string, id uint64) string {
+		return fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"%s","readId":%d}`,
+			testChatRoomName2, reqt, cont, id)
+	}
+
+	tTestExpectRsp(t, ws2, "Ack disabled", req(ChatReqTypeAck, "", 1), ChatRspTypeErrReceiptsDisabled,
+		fmt.Sprintf(`Read receipts are not enabled for room "%s".`, testChatRoomName2))
+	tTestExpectRsp(t, ws2, "Set receipts not owner", req(ChatReqTypeSetReceipts, "on", 0),
+		ChatRspTypeErrNotAuthorized, fmt.Sprintf(`You are not authorized to change room "%s".`, testChatRoomName2))
+	tTestExpectRsp(t, ws1, "Set receipts", req(ChatReqTypeSetReceipts, "on", 0), ChatRspTypeSetReceipts,
+		"Read receipts are now on for the room.")
+	tTestExpectRsp(t, ws2, "Set receipts", "", ChatRspTypeSetReceipts, "Read receipts are now on for the room.")
+
+	result, _ := tTestSendReceive(ws1, req(ChatReqTypeMsg, "Seen?", 0))
+	tTestReceive(ws2)
+	var msg ChatResponse
+	json.Unmarshal([]byte(result), &msg)
+	if msg.Message == nil {
+		t.Fatalf("Message should be returned with an ID. Actual: %s", result)
+	}
+	id := msg.Message.ID
+	read := fmt.Sprintf("%s has read up to message %d.", testChatterNickname2, id)
+	tTestExpectRsp(t, ws2, "Ack", req(ChatReqTypeAck, "", id), ChatRspTypeReceipt, read)
+	tTestExpectRsp(t, ws1, "Ack", "", ChatRspTypeReceipt, read)
+	ws2.Write([]byte(req(ChatReqTypeAck, "", id)))
+	tTestExpectRsp(t, ws2, "Unknown message", req(ChatReqTypeAck, "", id+1), ChatRspTypeErrMessageUnknown,
+		fmt.Sprintf(`Message %d is not in room "%s".`, id+1, testChatRoomName2))
+
+	result, _ = tTestSendReceive(ws1, req(ChatReqTypeReceipts, "", 0))
+	var rsp ChatResponse
+	json.Unmarshal([]byte(result), &rsp)
+	if rsp.RspType != ChatRspTypeReceipts || len(rsp.Receipts) != 1 || rsp.Receipts[testChatterNickname2] != id {
+		t.Errorf("Receipts incorrect. Actual: %s", result)
+	}
+	ws2.Write([]byte(req(ChatReqTypeTyping, "start", 0)))
+	tTestExpectRsp(t, ws1, "Typing after ack", "", ChatRspTypeTyping, "start")
+	result, _ = tTestSendReceive(ws1, req(ChatReqTypeReceipts, "", 0))
+	rsp = ChatResponse{}
+	json.Unmarshal([]byte(result), &rsp)
+	if rsp.Receipts[testChatterNickname2] != id {
+		t.Errorf("Typing should keep the receipt. Actual: %s", result)
+	}
+	tTestExpectRsp(t, ws2, "Leave after ack", fmt.Sprintf(`{"roomName":"%s","reqType":%d}`,
+		testChatRoomName2, ChatReqTypeLeave), ChatRspTypeLeave,
+		fmt.Sprintf(`You have left room "%s".`, testChatRoomName2))
+	tTestExpectRsp(t, ws1, "Leave after ack", "", ChatRspTypeLeave,
+		fmt.Sprintf("%s has left the room.", testChatterNickname2))
+	result, _ = tTestSendReceive(ws1, req(ChatReqTypeReceipts, "", 0))
+	rsp = ChatResponse{}
+	json.Unmarshal([]byte(result), &rsp)
+	if rsp.RspType != ChatRspTypeReceipts || len(rsp.Receipts) != 0 {
+		t.Errorf("Leaving should drop the receipt. Actual: %s", result)
+	}
+	tTestExpectRsp(t, ws1, "Receipts off", req(ChatReqTypeSetReceipts, "off", 0), ChatRspTypeSetReceipts,
+		"Read receipts are now off for the room.")
+}
+
+func TestServerPresence(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	testSrvr.cMngr.SetAwayIdle(2)
+	defer testSrvr.cMngr.SetAwayIdle(0)
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestSendReceive(ws2, TestServerJoin2)
+	tTestReceive(ws1)
+	names := fmt.Sprintf(`{"roomName":"%s","reqType":%d}`, testChatRoomName2, ChatReqTypeListNames)
+
+	busy := fmt.Sprintf("%s is now busy.", testChatterNickname2)
+	tTestExpectRsp(t, ws2, "Set presence", fmt.Sprintf(`{"reqType":%d,"content":"busy"}`, ChatReqTypeSetPresence),
+		ChatRspTypePresence, `Presence set to "busy".`)
+	tTestExpectRsp(t, ws2, "Presence broadcast", "", ChatRspTypePresence, busy)
+	tTestExpectRsp(t, ws1, "Presence broadcast", "", ChatRspTypePresence, busy)
+	result, _ := tTestSendReceive(ws1, names)
+	var rsp ChatResponse
+	json.Unmarshal([]byte(result), &rsp)
+	if len(rsp.Presence) != 2 || rsp.Presence[testChatterNickname1] != "online" ||
+		rsp.Presence[testChatterNickname2] != "busy" {
+		t.Errorf("List names should show presence. Actual: %s", result)
+	}
+
+	away := fmt.Sprintf("%s is now away.", testChatterNickname1)
+	tTestExpectRsp(t, ws2, "Idle chatter away", "", ChatRspTypePresence, away)
+	tTestExpectRsp(t, ws1, "Idle chatter stays connected", "", ChatRspTypePresence, away)
+	online := fmt.Sprintf("%s is now online.", testChatterNickname1)
+	tTestExpectRsp(t, ws1, "Active chatter online", names, ChatRspTypePresence, online)
+	tTestExpectRsp(t, ws1, "Active chatter online", "", ChatRspTypeListNames, "")
+	tTestExpectRsp(t, ws2, "Active chatter online", "", ChatRspTypePresence, online)
+}
+
+func TestServerPings(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	testSrvr.cMngr.SetLiveness(1, 1, 1)
+	defer testSrvr.cMngr.SetLiveness(0, 0, 0)
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+
+	// The first client reads, so it answers pings; the second does not.
+	ws1.SetReadDeadline(time.Now().Add(3500 * time.Millisecond))
+	if _, err := tTestReceive(ws1); err == nil {
+		t.Errorf("Pings should not be sent to the client as responses.")
+	}
+	ws1.SetReadDeadline(time.Time{})
+	tTestExpectRsp(t, ws1, "Client answering pings", fmt.Sprintf(`{"reqType":%d}`, ChatReqTypeGetNickname),
+		ChatRspTypeGetNickname, testChatterNickname1)
+	answered, gone := false, true
+	for _, st := range testSrvr.cMngr.getChatterStats() {
+		switch st.Nickname {
+		case testChatterNickname1:
+			answered = st.RTT > 0
+		case testChatterNickname2:
+			gone = false
+		}
+	}
+	if !answered {
+		t.Errorf("Round trip time of pings should be measured.")
+	}
+	if !gone {
+		t.Errorf("Client not answering pings should be removed.")
+	}
+	if _, err := tTestSendReceive(ws2, fmt.Sprintf(`{"reqType":%d}`, ChatReqTypeGetNickname)); err == nil {
+		t.Errorf("Client not answering pings should be disconnected.")
+	}
+}
+
+func TestServerHistory(t *testing.T) {
+	time.Sleep(1 * time.Second) // allow all connections to leave cleanly from previous test.
+	dir, err := ioutil.TempDir("", "chattypantz")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	testSrvr.cMngr.mu.Lock()
+	testSrvr.cMngr.histDir = dir
+	testSrvr.cMngr.mu.Unlock()
+	defer func() {
+		testSrvr.cMngr.mu.Lock()
+		testSrvr.cMngr.histDir = ""
+		testSrvr.cMngr.mu.Unlock()
+	}()
+	testSrvr.cMngr.deleteRoom(testChatRoomName2, nil) // Recreated with history.
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	ws2 := tTestDial(t, testChatterNickname2)
+	defer ws2.Close()
+	tTestSendReceive(ws1, TestServerJoin2)
+	tTestSendReceive(ws1, fmt.Sprintf(`{"roomName":"%s","reqType":%d,"content":"Remember me"}`,
+		testChatRoomName2, ChatReqTypeMsg))
+	page := `{"roomName":"%s","reqType":%d,"limit":10}`
+
+	tTestExpectRsp(t, ws2, "History not member", fmt.Sprintf(page, testChatRoomName2, ChatReqTypeHistoryPage),
+		ChatRspTypeErrNotMember, fmt.Sprintf(`You are not a member of room "%s".`, testChatRoomName2))
+	tTestExpectRsp(t, ws2, "History unknown room", fmt.Sprintf(page, testChatRoomName3, ChatReqTypeHistoryPage),
+		ChatRspTypeErrRoomNotFound, chatManagerErrRoomNotFound.Error())
+	if _, err := testSrvr.cMngr.find(testChatRoomName3); err == nil {
+		t.Errorf("History of an unknown room should not create the room.")
+	}
+	result, _ := tTestSendReceive(ws1, fmt.Sprintf(page, testChatRoomName2, ChatReqTypeHistoryPage))
+	var rsp ChatResponse
+	json.Unmarshal([]byte(result), &rsp)
+	if rsp.RspType != ChatRspTypeHistoryPage || rsp.Content != "0" || len(rsp.Messages) != 1 ||
+		rsp.Messages[0].Text != "Remember me" {
+		t.Errorf("History page should hold the message for a member. Actual: %s", result)
+	}
+}
+
 func TestHTTPRoutes(t *testing.T) {
 	client := &http.Client{}
 	rq, _ := http.NewRequest("GET", testSrvrURLAlive, nil)
@@ -1021,6 +2032,44 @@ func TestHTTPRoutes(t *testing.T) {
 		t.Errorf("/status returned invalid status code.\nExpected: %d\n\nActual: %d\n",
 			http.StatusOK, r.StatusCode)
 	}
+
+	r, _ = client.Get(testSrvrURLMetrics)
+	b, _ = ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	body = string(b)
+	if !strings.Contains(body, "# TYPE chattypantz_connections gauge") ||
+		!strings.Contains(body, `chattypantz_route_requests_total{route="/v1.0/stats"}`) {
+		t.Errorf("/metrics body incorrect.\nActual: %s\n", body)
+	}
+	if ct := r.Header.Get("Content-Type"); ct != metricsContentType {
+		t.Errorf("/metrics returned invalid content type.\nExpected: %s\n\nActual: %s\n",
+			metricsContentType, ct)
+	}
+}
+
+func TestServerDrainRestart(t *testing.T) {
+	time.Sleep(1 * time.Second)
+	ws1 := tTestDial(t, testChatterNickname1)
+	defer ws1.Close()
+	testSrvr.Shutdown()
+	tTestExpectRsp(t, ws1, "Shutdown notice", "", ChatRspTypeShutdown,
+		"Server shutting down, reconnect in 3 seconds.")
+	if testSrvr.isRunning() {
+		t.Errorf("Server should have shut down.")
+	}
+	if ws, err := websocket.Dial(testSrvrURL, "", testSrvrOrg); err == nil {
+		ws.Close()
+		t.Errorf("Server should not accept connections after shutdown.")
+	}
+
+	// The server can be started again.
+	go func() { testSrvr.Start() }()
+	time.Sleep(1 * time.Second)
+	if !testSrvr.isRunning() {
+		t.Errorf("Server should be running after a restart.")
+	}
+	ws2 := tTestDial(t, testChatterNickname2)
+	ws2.Close()
 }
 
 func TestServerTakeDown(t *testing.T) {
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+var (
+	// SlashCommandErrUsage is returned by a command to tell the chatter how the command is used.
+	SlashCommandErrUsage = errors.New("usage")
+
+	slashCommandErrInvalid = errors.New("command must have a one word name and a handler")
+)
+
+// SlashCommandFunc handles a slash command typed into a message in a room, ex: "/me waves", where
+// the arguments are the text after the command name. It returns a request to handle in place of
+// the message, as if the chatter had sent it, or a reply to send to the chatter. An error is sent
+// to the chatter instead.
+type SlashCommandFunc func(room string, nickname string, args string) (*ChatRequest, string, error)
+
+// SlashCommand is a command chatters may type into a message.
+type SlashCommand struct {
+	Name  string           // The name typed after the slash, ex: "me".
+	Usage string           // The arguments of the command, ex: "ACTION".
+	Help  string           // What the command does.
+	Run   SlashCommandFunc // Handles the command.
+}
+
+// usage returns how the command is typed.
+func (s *SlashCommand) usage() string {
+	return strings.TrimSpace("/" + s.Name + " " + s.Usage)
+}
+
+// slashCommandsNew returns the built-in slash commands by name.
+func slashCommandsNew() map[string]*SlashCommand {
+	cmds := make(map[string]*SlashCommand)
+	for _, s := range []*SlashCommand{
+		{"me", "ACTION", "Describe an action in the room.", slashCommandRequest(ChatReqTypeEmote, true)},
+		{"nick", "NICKNAME", "Change your nickname.", slashCommandNick},
+		{"join", "ROOM [PASSWORD]", "Join a room.", slashCommandJoin},
+		{"leave", "[ROOM]", "Leave this or another room.", slashCommandRoom(ChatReqTypeLeave)},
+		{"topic", "[TOPIC]", "Show or set the topic of the room.",
+			slashCommandRequest(ChatReqTypeTopic, false)},
+		{"who", "[ROOM]", "List the nicknames in this or another room.",
+			slashCommandRoom(ChatReqTypeListNames)},
+		{"msg", "NICKNAME MESSAGE", "Send a private message to a chatter.", slashCommandMsg},
+	} {
+		cmds[s.Name] = s
+	}
+	return cmds
+}
+
+// slashCommandParse splits a slash command into its name and arguments.
+func slashCommandParse(content string) (string, string) {
+	content = strings.TrimPrefix(content, "/")
+	name, args := content, ""
+	if i := strings.IndexAny(content, " \t"); i >= 0 {
+		name, args = content[:i], strings.TrimSpace(content[i+1:])
+	}
+	return strings.ToLower(name), args
+}
+
+// slashCommandRequest returns a command sending its arguments to the room as a request.
+func slashCommandRequest(reqt int, mandatory bool) SlashCommandFunc {
+	return func(room string, nickname string, args string) (*ChatRequest, string, error) {
+		if mandatory && args == "" {
+			return nil, "", SlashCommandErrUsage
+		}
+		return &ChatRequest{RoomName: room, ReqType: reqt, Content: args}, "", nil
+	}
+}
+
+// slashCommandRoom returns a command sending a request to the room named in its arguments, or the
+// room it was typed in.
+func slashCommandRoom(reqt int) SlashCommandFunc {
+	return func(room string, nickname string, args string) (*ChatRequest, string, error) {
+		if args != "" {
+			room = args
+		}
+		return &ChatRequest{RoomName: room, ReqType: reqt}, "", nil
+	}
+}
+
+// slashCommandNick changes the nickname of the chatter.
+func slashCommandNick(room string, nickname string, args string) (*ChatRequest, string, error) {
+	if args == "" || strings.ContainsAny(args, " \t") {
+		return nil, "", SlashCommandErrUsage
+	}
+	return &ChatRequest{ReqType: ChatReqTypeSetNickname, Content: args}, "", nil
+}
+
+// slashCommandJoin joins a room, with a password if one is given.
+func slashCommandJoin(room string, nickname string, args string) (*ChatRequest, string, error) {
+	f := strings.Fields(args)
+	if len(f) == 0 || len(f) > 2 {
+		return nil, "", SlashCommandErrUsage
+	}
+	q := &ChatRequest{RoomName: f[0], ReqType: ChatReqTypeJoin}
+	if len(f) == 2 {
+		q.Password = f[1]
+	}
+	return q, "", nil
+}
+
+// slashCommandMsg sends a private message to a chatter anywhere on the server.
+func slashCommandMsg(room string, nickname string, args string) (*ChatRequest, string, error) {
+	f := strings.SplitN(args, " ", 2)
+	if len(f) < 2 || strings.TrimSpace(f[1]) == "" {
+		return nil, "", SlashCommandErrUsage
+	}
+	q := &ChatRequest{ReqType: ChatReqTypePrivateMsg, Target: f[0], Content: strings.TrimSpace(f[1])}
+	return q, "", nil
+}
+
+// slashCommand translates a message typed as a slash command into the request it stands for. It
+// returns false if the command was answered and there is nothing more to do. A message starting
+// with two slashes is posted as typed, less one slash.
+func (c *Chatter) slashCommand(r *ChatRequest) bool {
+	if strings.HasPrefix(r.Content, "//") {
+		r.Content = r.Content[1:]
+		return true
+	}
+	name, args := slashCommandParse(r.Content)
+	if name == "help" {
+		c.sendResponse(r.RoomName, ChatRspTypeCommand, "Commands:", c.cMngr.slashCommandHelp())
+		return false
+	}
+	cmd := c.cMngr.slashCommand(name)
+	if cmd == nil {
+		c.sendResponse(r.RoomName, ChatRspTypeErrUnknownCommand,
+			fmt.Sprintf(`Unknown command "/%s". Type /help for a list of commands.`, name), nil)
+		return false
+	}
+	q, reply, err := cmd.Run(r.RoomName, c.Nickname(), args)
+	switch {
+	case err == SlashCommandErrUsage:
+		c.sendResponse(r.RoomName, ChatRspTypeErrCommand, "Usage: "+cmd.usage(), nil)
+	case err != nil:
+		c.sendResponse(r.RoomName, ChatRspTypeErrCommand, err.Error(), nil)
+	case q != nil:
+		*r = *q
+		return true
+	case reply != "":
+		c.sendResponse(r.RoomName, ChatRspTypeCommand, reply, nil)
+	}
+	return false
+}
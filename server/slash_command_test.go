@@ -0,0 +1,82 @@
+package server
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestSlashCommandParse(t *testing.T) {
+	t.Parallel()
+	tests := []struct {
+		in   string
+		name string
+		args string
+	}{
+		{"/me waves", "me", "waves"},
+		{"/JOIN  ops secret ", "join", "ops secret"},
+		{"/who", "who", ""},
+		{"/msg\tMonkey hi there", "msg", "Monkey hi there"},
+	}
+	for _, tc := range tests {
+		if name, args := slashCommandParse(tc.in); name != tc.name || args != tc.args {
+			t.Errorf("Parse of %q incorrect. Expected: %q %q Actual: %q %q", tc.in, tc.name, tc.args, name, args)
+		}
+	}
+}
+
+func TestSlashCommandsBuiltin(t *testing.T) {
+	t.Parallel()
+	cmds := slashCommandsNew()
+	tests := []struct {
+		name string
+		args string
+		exp  ChatRequest
+	}{
+		{"me", "waves", ChatRequest{RoomName: "Room", ReqType: ChatReqTypeEmote, Content: "waves"}},
+		{"nick", "Monkey", ChatRequest{ReqType: ChatReqTypeSetNickname, Content: "Monkey"}},
+		{"join", "ops", ChatRequest{RoomName: "ops", ReqType: ChatReqTypeJoin}},
+		{"join", "ops secret", ChatRequest{RoomName: "ops", ReqType: ChatReqTypeJoin, Password: "secret"}},
+		{"leave", "", ChatRequest{RoomName: "Room", ReqType: ChatReqTypeLeave}},
+		{"leave", "ops", ChatRequest{RoomName: "ops", ReqType: ChatReqTypeLeave}},
+		{"topic", "", ChatRequest{RoomName: "Room", ReqType: ChatReqTypeTopic}},
+		{"topic", "Bananas", ChatRequest{RoomName: "Room", ReqType: ChatReqTypeTopic, Content: "Bananas"}},
+		{"who", "", ChatRequest{RoomName: "Room", ReqType: ChatReqTypeListNames}},
+		{"msg", "Monkey hi there", ChatRequest{ReqType: ChatReqTypePrivateMsg, Target: "Monkey", Content: "hi there"}},
+	}
+	for _, tc := range tests {
+		q, _, err := cmds[tc.name].Run("Room", "Nick", tc.args)
+		if err != nil || q == nil || *q != tc.exp {
+			t.Errorf("/%s %s incorrect.\nExpected: %+v\n\nActual: %+v %v\n", tc.name, tc.args, tc.exp, q, err)
+		}
+	}
+	for _, c := range []struct{ name, args string }{{"me", ""}, {"nick", ""}, {"nick", "Two words"},
+		{"join", ""}, {"join", "a b c"}, {"msg", "Monkey"}} {
+		if _, _, err := cmds[c.name].Run("Room", "Nick", c.args); err != SlashCommandErrUsage {
+			t.Errorf("/%s %s should return usage. Actual: %v", c.name, c.args, err)
+		}
+	}
+}
+
+func TestAddSlashCommand(t *testing.T) {
+	t.Parallel()
+	m := ChatManagerNew(0, 0, 0, "", ChatLoggerNew())
+	run := func(room string, nickname string, args string) (*ChatRequest, string, error) {
+		return nil, "", errors.New("no")
+	}
+	for _, cmd := range []*SlashCommand{{Name: "", Run: run}, {Name: "help", Run: run},
+		{Name: "two words", Run: run}, {Name: "roll"}} {
+		if err := m.AddSlashCommand(cmd); err != slashCommandErrInvalid {
+			t.Errorf("Command %q should not be added. Actual: %v", cmd.Name, err)
+		}
+	}
+	if err := m.AddSlashCommand(&SlashCommand{"Roll", "[SIDES]", "Roll a die.", run}); err != nil {
+		t.Errorf("Command should be added: %v", err)
+	}
+	if cmd := m.slashCommand("roll"); cmd == nil || cmd.usage() != "/roll [SIDES]" {
+		t.Errorf("Command should be found ignoring case. Actual: %+v", cmd)
+	}
+	help := m.slashCommandHelp()
+	if len(help) != 9 || help[0] != "/help - List the commands." || help[6] != "/roll [SIDES] - Roll a die." {
+		t.Errorf("Help incorrect. Actual: %v", help)
+	}
+}
@@ -11,6 +11,8 @@ type Stats struct {
 	ReqCount     int64                       `json:"reqCount"`     // How many requests came in to the server.
 	ReqBytes     int64                       `json:"reqBytes"`     // Size of the requests in bytes.
 	RouteStats   map[string]map[string]int64 `json:"routeStats"`   // How many requests/bytes came into each route.
+	Throttled    uint64                      `json:"throttled"`    // How many chat requests were dropped by rate limits.
+	FloodKicks   uint64                      `json:"floodKicks"`   // How many chatters were disconnected for flooding.
 	ChatterStats []*ChatterStats             `json:"chatterStats"` // Statistics about each logged in chatter.
 	RoomStats    []*ChatRoomStats            `json:"roomStats"`    // How many requests etc came into each room.
 }
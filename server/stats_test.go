@@ -10,7 +10,8 @@ import (
 const (
 	testStatsExpectedJSONResult = `{"startTime":"2006-01-02T13:24:56Z","reqCount":0,` +
 		`"reqBytes":0,"routeStats":{"route1":{"requesBytes":202,"requestCounts":101},` +
-		`"route2":{"requesBytes":204,"requestCounts":103}},"chatterStats":[],"roomStats":[]}`
+		`"route2":{"requesBytes":204,"requestCounts":103}},"throttled":0,"floodKicks":0,` +
+		`"chatterStats":[],"roomStats":[]}`
 )
 
 func TestStatsNew(t *testing.T) {
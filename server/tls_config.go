@@ -0,0 +1,139 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+)
+
+var (
+	tlsCheckInterval = 10 * time.Second // How often the certificate files are checked for changes.
+
+	tlsErrKeyPair = errors.New("TLS certificate and key files must both be provided")
+	tlsErrCA      = errors.New("TLS client CA file contains no certificates")
+)
+
+// tlsReloader keeps the TLS configuration of the server and loads it again from the certificate,
+// key and client CA files whenever they change, so certificates can be renewed without a restart.
+type tlsReloader struct {
+	mu       sync.RWMutex // Lock for update.
+	certFile string       // The PEM file of the server certificate chain.
+	keyFile  string       // The PEM file of the server private key.
+	caFile   string       // The PEM file of the CAs client certificates must be signed by, if any.
+	config   *tls.Config  // The configuration loaded from the files.
+	modTime  time.Time    // The latest modification time of the files when they were loaded.
+	checked  time.Time    // The last time the files were checked for changes.
+	log      *ChatLogger  // Application log for events.
+}
+
+// tlsReloaderNew is a factory function that returns a new TLS reloader with the files loaded.
+func tlsReloaderNew(certFile string, keyFile string, caFile string, l *ChatLogger) (*tlsReloader, error) {
+	if certFile == "" || keyFile == "" {
+		return nil, tlsErrKeyPair
+	}
+	t := &tlsReloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		log:      l,
+	}
+	if err := t.reload(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// reload loads the certificate, key and client CA files.
+func (t *tlsReloader) reload() error {
+	mod, err := t.latestModTime()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(t.certFile, t.keyFile)
+	if err != nil {
+		return err
+	}
+	cfg := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if t.caFile != "" {
+		pem, err := ioutil.ReadFile(t.caFile)
+		if err != nil {
+			return err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return tlsErrCA
+		}
+		cfg.ClientCAs = pool
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.config = cfg
+	t.modTime = mod
+	t.checked = time.Now()
+	return nil
+}
+
+// latestModTime returns the most recent modification time of the files.
+func (t *tlsReloader) latestModTime() (time.Time, error) {
+	var mod time.Time
+	for _, f := range []string{t.certFile, t.keyFile, t.caFile} {
+		if f == "" {
+			continue
+		}
+		fi, err := os.Stat(f)
+		if err != nil {
+			return mod, err
+		}
+		if fi.ModTime().After(mod) {
+			mod = fi.ModTime()
+		}
+	}
+	return mod, nil
+}
+
+// current returns the loaded configuration, first loading the files again if they have changed.
+// If the changed files cannot be loaded the previous configuration is kept.
+func (t *tlsReloader) current() (*tls.Config, error) {
+	t.mu.Lock()
+	due := time.Since(t.checked) >= tlsCheckInterval
+	if due {
+		t.checked = time.Now()
+	}
+	t.mu.Unlock()
+	if due {
+		if mod, err := t.latestModTime(); err == nil && mod.After(t.loadedModTime()) {
+			if err := t.reload(); err != nil {
+				t.log.Errorf("Cannot reload TLS certificates: %s", err.Error())
+			} else {
+				t.log.Infof("TLS certificates reloaded.")
+			}
+		}
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.config, nil
+}
+
+// loadedModTime returns the modification time of the files when they were loaded.
+func (t *tlsReloader) loadedModTime() time.Time {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	return t.modTime
+}
+
+// tlsConfig returns the configuration for the listener. Each handshake uses the current files.
+func (t *tlsReloader) tlsConfig() *tls.Config {
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			return t.current()
+		},
+	}
+}
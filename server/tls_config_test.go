@@ -0,0 +1,141 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// tTestTLSCert creates a certificate signed by the parent, or self signed if there is no parent,
+// and writes it and its key to PEM files in the directory.
+func tTestTLSCert(t *testing.T, dir string, name string, serial int64, parent *x509.Certificate,
+	parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Cannot generate key: %s", err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatalf("Cannot create certificate: %s", err)
+	}
+	kder, _ := x509.MarshalECPrivateKey(key)
+	ioutil.WriteFile(filepath.Join(dir, name+".pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	ioutil.WriteFile(filepath.Join(dir, name+".key"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: kder}), 0600)
+	cert, _ := x509.ParseCertificate(der)
+	return cert, key
+}
+
+// tTestTLSHandshake serves one TLS handshake with the reloader and returns the client result.
+func tTestTLSHandshake(t *testing.T, rl *tlsReloader, client *tls.Config) (*tls.ConnectionState, error) {
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", rl.tlsConfig())
+	if err != nil {
+		t.Fatalf("Cannot listen: %s", err)
+	}
+	defer ln.Close()
+	go func() {
+		if c, err := ln.Accept(); err == nil {
+			c.(*tls.Conn).Handshake()
+			c.Close()
+		}
+	}()
+	conn, err := tls.Dial("tcp", ln.Addr().String(), client)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	// With TLS 1.3 a rejected client certificate is only reported on the first read.
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err != nil && !isTimeoutOrEOF(err) {
+		return nil, err
+	}
+	st := conn.ConnectionState()
+	return &st, nil
+}
+
+// isTimeoutOrEOF validates whether a read error is a normal end of the test connection.
+func isTimeoutOrEOF(err error) bool {
+	if e, ok := err.(net.Error); ok && e.Timeout() {
+		return true
+	}
+	return err.Error() == "EOF"
+}
+
+func TestTLSReloader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "chattypantz")
+	if err != nil {
+		t.Fatalf("Cannot create temp dir: %s", err)
+	}
+	defer os.RemoveAll(dir)
+	ca, caKey := tTestTLSCert(t, dir, "ca", 1, nil, nil)
+	tTestTLSCert(t, dir, "server", 2, ca, caKey)
+	clientCert, clientKey := tTestTLSCert(t, dir, "client", 3, ca, caKey)
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+
+	if _, err := tlsReloaderNew(filepath.Join(dir, "server.pem"), "", "", ChatLoggerNew()); err != tlsErrKeyPair {
+		t.Errorf("Reloader should require a key. Err: %v", err)
+	}
+	rl, err := tlsReloaderNew(filepath.Join(dir, "server.pem"), filepath.Join(dir, "server.key"),
+		filepath.Join(dir, "ca.pem"), ChatLoggerNew())
+	if err != nil {
+		t.Fatalf("Reloader should have been created. Err: %s", err)
+	}
+
+	// Client certificates are required when a CA is given.
+	if _, err := tTestTLSHandshake(t, rl, &tls.Config{RootCAs: pool}); err == nil {
+		t.Errorf("Handshake without a client certificate should have failed.")
+	}
+	client := &tls.Config{
+		RootCAs: pool,
+		Certificates: []tls.Certificate{{
+			Certificate: [][]byte{clientCert.Raw},
+			PrivateKey:  clientKey,
+		}},
+	}
+	st, err := tTestTLSHandshake(t, rl, client)
+	if err != nil {
+		t.Fatalf("Handshake with a client certificate should have succeeded. Err: %s", err)
+	}
+	if serial := st.PeerCertificates[0].SerialNumber.Int64(); serial != 2 {
+		t.Errorf("Server certificate serial incorrect. Actual: %d", serial)
+	}
+
+	// A renewed certificate is picked up without a restart.
+	tlsCheckInterval = 0
+	defer func() { tlsCheckInterval = 10 * time.Second }()
+	tTestTLSCert(t, dir, "server", 4, ca, caKey)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(filepath.Join(dir, "server.pem"), later, later)
+	st, err = tTestTLSHandshake(t, rl, client)
+	if err != nil {
+		t.Fatalf("Handshake after reload should have succeeded. Err: %s", err)
+	}
+	if serial := st.PeerCertificates[0].SerialNumber.Int64(); serial != 4 {
+		t.Errorf("Reloaded server certificate serial incorrect. Actual: %d", serial)
+	}
+}
@@ -11,6 +11,7 @@ Description: chattypantz is a chat server allowing clients to text each other wi
 Usage: chattypantz [options...]
 
 Server options:
+    -C, --config FILE                Load options from a JSON or YAML FILE (default: none).
     -N, --name NAME                  NAME of the server (default: empty field).
     -H, --hostname HOSTNAME          HOSTNAME of the server (default: localhost).
     -p, --port PORT                  PORT to listen on (default: 6660).
@@ -19,6 +20,42 @@ Server options:
     -r, --rooms MAX                  *MAX chatrooms allowed (default: unlimited).
     -i, --idle MAX                   *MAX idle time in seconds allowed (default: unlimited).
     -X, --procs MAX                  *MAX processor cores to use from the machine.
+    -y, --history MAX                *MAX history messages replayed to a chatter on join.
+    -Y, --history_dir DIR            DIR where room history is stored (default: not stored).
+    -a, --admins NAMES               Comma separated proven NAMES allowed to administer any room.
+    -k, --auth_secret SECRET         Require chat connections to carry a JWT signed with SECRET.
+    -R, --nickname_file FILE         FILE where registered nicknames are stored (default: memory only).
+    -u, --unique_nicknames           Require nicknames to be unique across the server (default: false).
+    -c, --tls_cert FILE              Serve wss:// and https:// with the certificate in FILE.
+    -K, --tls_key FILE               Serve wss:// and https:// with the private key in FILE.
+    -A, --tls_ca FILE                Require client certificates signed by a CA in FILE.
+    -b, --bans NAMES                 Comma separated nicknames and IPs banned from the server.
+    -m, --motd TEXT                  Message of the day sent to chatters when they connect.
+    -s, --msg_rate MSGS              MSGS per second allowed to each chatter (default: 0 unlimited).
+    -B, --msg_burst MSGS             MSGS allowed at once before the rate applies (default: 1).
+    -j, --join_rate JOINS            JOINS of rooms per minute allowed to each chatter (default: 0 unlimited).
+    -f, --flood_limit COUNT          COUNT of throttled requests per minute before a chatter is
+                                     disconnected (default: 0 never).
+    -F, --max_frame BYTES            BYTES allowed in a request frame (default: 65536).
+    -M, --max_message CHARS          CHARS allowed in a message (default: 4096).
+    -e, --max_nickname CHARS         CHARS allowed in a nickname (default: 32).
+    -o, --max_room_name CHARS        CHARS allowed in a room name (default: 64).
+    -W, --word_file FILE             FILE of words masked in messages, one per line.
+    -l, --strip_links                Remove links from messages.
+    -P, --block PATTERNS             Comma separated regular expression PATTERNS of messages
+                                     that are refused.
+    -T, --typing_timeout SECONDS     SECONDS a typing notice lasts without a stop (default: 5).
+    -I, --typing_idle                Typing notices reset the idle timeout (default: false).
+    -z, --away SECONDS               *SECONDS idle before a chatter is shown as away, shorter
+                                     than the idle disconnect (default: off).
+    -G, --ping SECONDS               *SECONDS between pings to each chatter (default: 30).
+    -Q, --pong_timeout SECONDS       *SECONDS a chatter has to answer a ping before it is
+                                     disconnected (default: 10).
+    -D, --write_timeout SECONDS      *SECONDS a send to a chatter may block before it is
+                                     disconnected (default: 10).
+    -g, --grace SECONDS              SECONDS allowed on shutdown for queues to drain (default: 5).
+    -w, --reconnect SECONDS          SECONDS chatters are asked to wait to reconnect (default: 10).
+    -t, --admin_token TOKEN          TOKEN to authorize admin API requests (default: disabled).
 
     -d, --debug                      Enable debugging output (default: false)
 
@@ -31,8 +68,8 @@ Common options:
 Examples:
 
     # Server mode activated as "San Francisco" on host 0.0.0.0 port 6661;
-	# 10 clients; 50 rooms; one hour idle allowed; 2 processors
-    chattypantz -N "San Francisco" -H 0.0.0.0 -p 6661 -n 10 -r 50 -i 3600 -X 2
+	# 10 clients; 50 rooms; one hour idle allowed; 2 processors; replay 20 messages
+    chattypantz -N "San Francisco" -H 0.0.0.0 -p 6661 -n 10 -r 50 -i 3600 -X 2 -y 20
 `
 
 // end help text